@@ -1,2 +1,2 @@
 #!/usr/bin/env bash
-go build -ldflags "-s"
+go build -ldflags "-s" ./cmd/dapperdox
@@ -0,0 +1,425 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package changelog
+
+// This package compares two revisions of a specification, listing the operations,
+// parameters, responses, resources and properties that were added, removed or
+// modified. Changes that may break existing clients are flagged: removing an
+// operation, parameter, response or property, making a parameter or property
+// required, changing a type, and removing possible values from an enumeration.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// Kinds of change
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// What changed
+const (
+	CategoryOperation = "operation"
+	CategoryParameter = "parameter"
+	CategoryResponse  = "response"
+	CategoryResource  = "resource"
+	CategoryProperty  = "property"
+)
+
+// Nesting beyond which properties are not compared, in case of recursive models
+const maxDepth = 16
+
+// Change is a difference between two revisions of a specification
+type Change struct {
+	Kind        string `json:"kind"`
+	Category    string `json:"category"`
+	Name        string `json:"name"` // Such as "GET /pets", "GET /pets query limit" or "pet.category.id"
+	Description string `json:"description"`
+	Breaking    bool   `json:"breaking"`
+}
+
+// Changelog lists the changes from one revision of a specification to another
+type Changelog struct {
+	From     string   `json:"from"` // Version of the earlier revision
+	To       string   `json:"to"`
+	Changes  []Change `json:"changes"`
+	Breaking int      `json:"breaking"` // Number of breaking changes
+}
+
+// ---------------------------------------------------------------------------
+// Compare returns the changes made to a specification from revision from to
+// revision to.
+func Compare(from *spec.APISpecification, to *spec.APISpecification) *Changelog {
+	cl := &Changelog{From: from.Version, To: to.Version, Changes: []Change{}}
+
+	cl.compareMethods(methods(from), methods(to))
+	cl.compareResources(resources(from), resources(to))
+
+	return cl.sorted()
+}
+
+// ---------------------------------------------------------------------------
+// Sorts the changes by category, and counts those that are breaking.
+func (cl *Changelog) sorted() *Changelog {
+	sort.Stable(byCategory(cl.Changes))
+
+	cl.Breaking = 0
+	for _, c := range cl.Changes {
+		if c.Breaking {
+			cl.Breaking++
+		}
+	}
+	return cl
+}
+
+// ---------------------------------------------------------------------------
+
+func (cl *Changelog) add(kind string, category string, name string, breaking bool, format string, args ...interface{}) {
+	cl.Changes = append(cl.Changes, Change{
+		Kind:        kind,
+		Category:    category,
+		Name:        name,
+		Description: fmt.Sprintf(format, args...),
+		Breaking:    breaking,
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Methods are keyed by HTTP method and path, as their IDs may change between
+// revisions without changing the API.
+func methods(s *spec.APISpecification) map[string]*spec.Method {
+	m := make(map[string]*spec.Method)
+	for _, api := range s.APIs {
+		for i := range api.Methods {
+			method := &api.Methods[i]
+			m[strings.ToUpper(method.Method)+" "+method.Path] = method
+		}
+	}
+	return m
+}
+
+func resources(s *spec.APISpecification) map[string]*spec.Resource {
+	r := make(map[string]*spec.Resource)
+	for _, list := range s.ResourceList {
+		for id, resource := range list {
+			if _, ok := r[id]; !ok {
+				r[id] = resource
+			}
+		}
+	}
+	return r
+}
+
+// ---------------------------------------------------------------------------
+
+func (cl *Changelog) compareMethods(from map[string]*spec.Method, to map[string]*spec.Method) {
+	for _, name := range sortedKeys(from, to) {
+		f, t := from[name], to[name]
+		switch {
+		case t == nil:
+			cl.add(Removed, CategoryOperation, name, true, "Operation removed")
+		case f == nil:
+			cl.add(Added, CategoryOperation, name, false, "Operation added%s", summary(t))
+		default:
+			cl.compareParameters(name, parameters(f), parameters(t))
+			cl.compareBody(name, f.BodyParam, t.BodyParam)
+			cl.compareResponses(name, f, t)
+		}
+	}
+}
+
+func summary(m *spec.Method) string {
+	if m.Name == "" {
+		return ""
+	}
+	return ": " + m.Name
+}
+
+// ---------------------------------------------------------------------------
+// Parameters, other than the body, keyed by location and name.
+func parameters(m *spec.Method) map[string]spec.Parameter {
+	p := make(map[string]spec.Parameter)
+	for _, params := range [][]spec.Parameter{m.PathParams, m.QueryParams, m.HeaderParams, m.FormParams} {
+		for _, param := range params {
+			p[param.In+" "+param.Name] = param
+		}
+	}
+	return p
+}
+
+func (cl *Changelog) compareParameters(method string, from map[string]spec.Parameter, to map[string]spec.Parameter) {
+	for _, key := range sortedKeys(from, to) {
+		f, inFrom := from[key]
+		t, inTo := to[key]
+		name := method + " " + key
+
+		switch {
+		case !inTo:
+			cl.add(Removed, CategoryParameter, name, true, "Parameter removed")
+		case !inFrom:
+			if t.Required {
+				cl.add(Added, CategoryParameter, name, true, "Required parameter added")
+			} else {
+				cl.add(Added, CategoryParameter, name, false, "Optional parameter added")
+			}
+		default:
+			if !f.Required && t.Required {
+				cl.add(Modified, CategoryParameter, name, true, "Parameter is now required")
+			} else if f.Required && !t.Required {
+				cl.add(Modified, CategoryParameter, name, false, "Parameter is now optional")
+			}
+			cl.compareTypes(CategoryParameter, name, f.Type, t.Type)
+			cl.compareEnums(CategoryParameter, name, f.Enum, t.Enum)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Compares the request bodies of a method. Changes to the body resource itself are
+// listed with the resources.
+func (cl *Changelog) compareBody(method string, from *spec.Parameter, to *spec.Parameter) {
+	name := method + " body"
+
+	switch {
+	case from == nil && to == nil:
+		return
+	case to == nil:
+		cl.add(Removed, CategoryParameter, name, true, "Request body removed")
+	case from == nil:
+		cl.add(Added, CategoryParameter, name, to.Required, "Request body added")
+	default:
+		if !from.Required && to.Required {
+			cl.add(Modified, CategoryParameter, name, true, "Request body is now required")
+		}
+		if from.IsArray != to.IsArray || resourceID(from.Resource) != resourceID(to.Resource) {
+			cl.add(Modified, CategoryParameter, name, true, "Request body changed from %s to %s", bodyName(from.Resource, from.IsArray), bodyName(to.Resource, to.IsArray))
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func (cl *Changelog) compareResponses(method string, from *spec.Method, to *spec.Method) {
+	codes := make(map[int]bool)
+	for code := range from.Responses {
+		codes[code] = true
+	}
+	for code := range to.Responses {
+		codes[code] = true
+	}
+	var sorted []int
+	for code := range codes {
+		sorted = append(sorted, code)
+	}
+	sort.Ints(sorted)
+
+	for _, code := range sorted {
+		f, inFrom := from.Responses[code]
+		t, inTo := to.Responses[code]
+		name := fmt.Sprintf("%s %d", method, code)
+
+		switch {
+		case !inTo:
+			cl.add(Removed, CategoryResponse, name, true, "Response removed")
+		case !inFrom:
+			cl.add(Added, CategoryResponse, name, false, "Response added")
+		case f.IsArray != t.IsArray || resourceID(f.Resource) != resourceID(t.Resource):
+			cl.add(Modified, CategoryResponse, name, true, "Response changed from %s to %s", bodyName(f.Resource, f.IsArray), bodyName(t.Resource, t.IsArray))
+		}
+	}
+}
+
+func resourceID(r *spec.Resource) string {
+	if r == nil {
+		return ""
+	}
+	return r.ID
+}
+
+func bodyName(r *spec.Resource, isArray bool) string {
+	if r == nil {
+		return "no resource"
+	}
+	name := r.Title
+	if name == "" {
+		name = r.ID
+	}
+	if isArray {
+		name += "[]"
+	}
+	return name
+}
+
+// ---------------------------------------------------------------------------
+
+func (cl *Changelog) compareResources(from map[string]*spec.Resource, to map[string]*spec.Resource) {
+	for _, id := range sortedKeys(from, to) {
+		f, t := from[id], to[id]
+		switch {
+		case t == nil:
+			cl.add(Removed, CategoryResource, id, true, "Resource removed")
+		case f == nil:
+			cl.add(Added, CategoryResource, id, false, "Resource added")
+		default:
+			cl.compareProperties(id, f, t, 0)
+		}
+	}
+}
+
+// Compares the properties of two revisions of a resource, and of their nested
+// resources. Properties are named by their path from the top level resource.
+func (cl *Changelog) compareProperties(path string, from *spec.Resource, to *spec.Resource, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	for _, key := range sortedKeys(from.Properties, to.Properties) {
+		f, t := from.Properties[key], to.Properties[key]
+		name := path + "." + key
+
+		switch {
+		case t == nil:
+			cl.add(Removed, CategoryProperty, name, true, "Property removed")
+		case f == nil:
+			if t.Required {
+				cl.add(Added, CategoryProperty, name, true, "Required property added")
+			} else {
+				cl.add(Added, CategoryProperty, name, false, "Optional property added")
+			}
+		default:
+			if !f.Required && t.Required {
+				cl.add(Modified, CategoryProperty, name, true, "Property is now required")
+			} else if f.Required && !t.Required {
+				cl.add(Modified, CategoryProperty, name, false, "Property is now optional")
+			}
+			if !f.ReadOnly && t.ReadOnly {
+				cl.add(Modified, CategoryProperty, name, true, "Property is now read only")
+			}
+			cl.compareTypes(CategoryProperty, name, f.Type, t.Type)
+			cl.compareEnums(CategoryProperty, name, f.Enum, t.Enum)
+			cl.compareProperties(name, f, t, depth+1)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func (cl *Changelog) compareTypes(category string, name string, from []string, to []string) {
+	f, t := strings.Join(from, " of "), strings.Join(to, " of ")
+	if f != t {
+		cl.add(Modified, category, name, true, "Type changed from %s to %s", f, t)
+	}
+}
+
+// Removing possible values breaks clients using them. Adding them does not, as
+// clients should expect values they do not know of.
+func (cl *Changelog) compareEnums(category string, name string, from []string, to []string) {
+	if len(from) == 0 && len(to) == 0 {
+		return
+	}
+	if len(from) == 0 {
+		cl.add(Modified, category, name, true, "Restricted to the possible values %s", strings.Join(to, ", "))
+		return
+	}
+	if len(to) == 0 {
+		cl.add(Modified, category, name, false, "No longer restricted to the possible values %s", strings.Join(from, ", "))
+		return
+	}
+
+	removed := difference(from, to)
+	added := difference(to, from)
+
+	if len(removed) > 0 {
+		cl.add(Modified, category, name, true, "Possible values removed: %s", strings.Join(removed, ", "))
+	}
+	if len(added) > 0 {
+		cl.add(Modified, category, name, false, "Possible values added: %s", strings.Join(added, ", "))
+	}
+}
+
+// Returns the members of a that are not in b
+func difference(a []string, b []string) []string {
+	in := make(map[string]bool)
+	for _, s := range b {
+		in[s] = true
+	}
+	var d []string
+	for _, s := range a {
+		if !in[s] {
+			d = append(d, s)
+		}
+	}
+	return d
+}
+
+// ---------------------------------------------------------------------------
+// Returns the keys of two maps, sorted.
+func sortedKeys(a interface{}, b interface{}) []string {
+	seen := make(map[string]bool)
+	var keys []string
+
+	add := func(k string) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	for _, m := range []interface{}{a, b} {
+		switch m := m.(type) {
+		case map[string]*spec.Method:
+			for k := range m {
+				add(k)
+			}
+		case map[string]spec.Parameter:
+			for k := range m {
+				add(k)
+			}
+		case map[string]*spec.Resource:
+			for k := range m {
+				add(k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ---------------------------------------------------------------------------
+
+var categoryOrder = map[string]int{
+	CategoryOperation: 0,
+	CategoryParameter: 1,
+	CategoryResponse:  2,
+	CategoryResource:  3,
+	CategoryProperty:  4,
+}
+
+type byCategory []Change
+
+func (c byCategory) Len() int      { return len(c) }
+func (c byCategory) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCategory) Less(i, j int) bool {
+	return categoryOrder[c[i].Category] < categoryOrder[c[j].Category]
+}
+
+// ---------------------------------------------------------------------------
@@ -0,0 +1,201 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package changelog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// A comparison sets out two versions of an operation or resource side by side, a
+// field at a time, marking the fields that changed.
+
+// Sections of a comparison, in the order they are listed
+const (
+	sectionParameter = iota
+	sectionBody
+	sectionResponse
+	sectionProperty
+)
+
+// Field is a parameter, request body, response or property, as described in each
+// of the versions compared.
+type Field struct {
+	Name     string `json:"name"`
+	From     string `json:"from"` // Empty if the field was added
+	To       string `json:"to"`   // Empty if the field was removed
+	Kind     string `json:"kind,omitempty"`
+	Breaking bool   `json:"breaking,omitempty"`
+
+	key     string // Name of the field's changes
+	section int
+}
+
+// Comparison is the changelog between two versions of an operation or resource,
+// with the fields of both.
+type Comparison struct {
+	*Changelog
+	Fields []Field `json:"fields"`
+}
+
+// ---------------------------------------------------------------------------
+// CompareMethod compares two versions of an operation.
+func CompareMethod(from *spec.Method, to *spec.Method, fromVersion string, toVersion string) *Comparison {
+	cl := &Changelog{From: fromVersion, To: toVersion, Changes: []Change{}}
+
+	name := strings.ToUpper(to.Method) + " " + to.Path
+
+	cl.compareParameters(name, parameters(from), parameters(to))
+	cl.compareBody(name, from.BodyParam, to.BodyParam)
+	cl.compareResponses(name, from, to)
+	cl.compareResources(methodResources(from), methodResources(to))
+
+	return compared(cl.sorted(), methodFields(name, from), methodFields(name, to))
+}
+
+// CompareResource compares two versions of a resource.
+func CompareResource(from *spec.Resource, to *spec.Resource, fromVersion string, toVersion string) *Comparison {
+	cl := &Changelog{From: fromVersion, To: toVersion, Changes: []Change{}}
+
+	cl.compareProperties(to.ID, from, to, 0)
+
+	var f, t []Field
+	propertyFields(&f, from.ID, from, 0)
+	propertyFields(&t, to.ID, to, 0)
+
+	return compared(cl.sorted(), f, t)
+}
+
+// ---------------------------------------------------------------------------
+// Merges the fields of two versions, marking those with changes.
+func compared(cl *Changelog, from []Field, to []Field) *Comparison {
+	c := &Comparison{Changelog: cl}
+
+	index := make(map[string]int)
+	for _, f := range from {
+		index[f.key] = len(c.Fields)
+		c.Fields = append(c.Fields, Field{Name: f.Name, From: f.To, key: f.key, section: f.section})
+	}
+	for _, t := range to {
+		if i, ok := index[t.key]; ok {
+			c.Fields[i].To = t.To
+			continue
+		}
+		c.Fields = append(c.Fields, Field{Name: t.Name, To: t.To, key: t.key, section: t.section})
+	}
+	sort.SliceStable(c.Fields, func(i, j int) bool { return c.Fields[i].section < c.Fields[j].section })
+
+	for i := range c.Fields {
+		field := &c.Fields[i]
+		for _, change := range cl.Changes {
+			if change.Name == field.key {
+				if field.Kind == "" {
+					field.Kind = change.Kind
+				}
+				field.Breaking = field.Breaking || change.Breaking
+			}
+		}
+	}
+	return c
+}
+
+// ---------------------------------------------------------------------------
+// Returns the fields of a method, described in To. Fields are keyed by the names
+// given to their changes.
+func methodFields(name string, m *spec.Method) []Field {
+	var fields []Field
+
+	for _, params := range [][]spec.Parameter{m.PathParams, m.QueryParams, m.HeaderParams, m.FormParams} {
+		for _, p := range params {
+			fields = append(fields, Field{Name: p.In + " " + p.Name, To: describe(p.Type, p.Required, false, p.Enum), key: name + " " + p.In + " " + p.Name, section: sectionParameter})
+		}
+	}
+
+	if body := m.BodyParam; body != nil {
+		description := bodyName(body.Resource, body.IsArray)
+		if body.Required {
+			description += ", required"
+		}
+		fields = append(fields, Field{Name: "body", To: description, key: name + " body", section: sectionBody})
+	}
+
+	var codes []int
+	for code := range m.Responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		response := m.Responses[code]
+		fields = append(fields, Field{Name: fmt.Sprintf("response %d", code), To: bodyName(response.Resource, response.IsArray), key: fmt.Sprintf("%s %d", name, code), section: sectionResponse})
+	}
+
+	resources := methodResources(m)
+	for _, id := range sortedKeys(resources, nil) {
+		propertyFields(&fields, id, resources[id], 0)
+	}
+	return fields
+}
+
+// Returns the resources of a method's request body and responses, keyed by ID.
+func methodResources(m *spec.Method) map[string]*spec.Resource {
+	r := make(map[string]*spec.Resource)
+	for _, response := range m.Responses {
+		if response.Resource != nil {
+			r[response.Resource.ID] = response.Resource
+		}
+	}
+	if m.BodyParam != nil && m.BodyParam.Resource != nil {
+		if _, ok := r[m.BodyParam.Resource.ID]; !ok {
+			r[m.BodyParam.Resource.ID] = m.BodyParam.Resource
+		}
+	}
+	return r
+}
+
+// Appends the properties of a resource, and of its nested resources, named by their
+// path from the top level resource as their changes are.
+func propertyFields(fields *[]Field, path string, r *spec.Resource, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	for _, key := range sortedKeys(r.Properties, nil) {
+		property := r.Properties[key]
+		name := path + "." + key
+		*fields = append(*fields, Field{Name: name, To: describe(property.Type, property.Required, property.ReadOnly, property.Enum), key: name, section: sectionProperty})
+		propertyFields(fields, name, property, depth+1)
+	}
+}
+
+func describe(types []string, required bool, readOnly bool, enum []string) string {
+	description := strings.Join(types, " of ")
+	if required {
+		description += ", required"
+	}
+	if readOnly {
+		description += ", read only"
+	}
+	if len(enum) > 0 {
+		description += ", one of " + strings.Join(enum, ", ")
+	}
+	return description
+}
+
+// ---------------------------------------------------------------------------
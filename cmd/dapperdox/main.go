@@ -0,0 +1,159 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dapperdox/dapperdox"
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/lint"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/network"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+var VERSION string = "1.2.1"
+
+const watchInterval = 1 * time.Second
+
+// ---------------------------------------------------------------------------
+func main() {
+	// The lint command is given ahead of any options. Remove it, so that the options are parsed.
+	linting := len(os.Args) > 1 && os.Args[1] == "lint"
+	if linting {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		log.Printf("DapperDox version %s linting specifications\n", VERSION)
+	} else {
+		log.Printf("DapperDox server version %s starting\n", VERSION)
+	}
+
+	os.Setenv("GOFIGURE_ENV_ARRAY", "1") // Enable gofigure array parsing of env vars
+
+	cfg, err := config.Get()
+	if err != nil {
+		log.Fatalf("error configuring app: %s", err)
+	}
+
+	// logging before this point must rely on setting LOGLEVEL env var
+	if l, err := logger.LevelFromString(cfg.LogLevel); err == nil {
+		logger.DefaultLevel = l
+	} else {
+		logger.Errorf(nil, "error setting log level: %s", err)
+		os.Exit(1)
+	}
+
+	if linting {
+		os.Exit(lintSpecifications(cfg))
+	}
+
+	// Specifications are loaded once listening, so that /healthz answers meanwhile,
+	// unless exporting them
+	server, err := dapperdox.New(dapperdox.Options{Config: cfg, Version: VERSION, Background: len(cfg.ExportDir) == 0})
+	if err != nil {
+		logger.Errorf(nil, "error configuring app: %s", err)
+		os.Exit(1)
+	}
+
+	if len(cfg.ExportDir) != 0 {
+		if err := server.Export(cfg.ExportDir); err != nil {
+			logger.Errorf(nil, "Export error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	listener, err := network.GetListener(cfg)
+	if err != nil {
+		logger.Errorf(nil, "Error listening on %s: %s", cfg.BindAddr, err)
+		os.Exit(1)
+	}
+
+	if cfg.Watch {
+		go server.Watch(watchInterval)
+	}
+
+	timeout, _ := time.ParseDuration(cfg.ShutdownTimeout) // Validated with the configuration
+
+	if err := serve(listener, server, timeout); err != nil {
+		logger.Errorf(nil, "Server error: %s", err)
+		os.Exit(1)
+	}
+	logger.Infof(nil, "DapperDox server stopped")
+}
+
+// ---------------------------------------------------------------------------
+// Serves handler until SIGTERM or SIGINT is received. New connections are then
+// refused, and the requests in progress, including proxied requests, given until the
+// timeout to complete. A second signal exits at once.
+func serve(listener net.Listener, handler http.Handler, timeout time.Duration) error {
+	srv := &http.Server{Handler: handler}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		signal.Stop(signals)
+		logger.Infof(nil, "Received %s, waiting up to %s for requests in progress to complete", sig, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warnf(nil, "Abandoning requests still in progress: %s", err)
+		return srv.Close()
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Loads the specifications without serving them, writing a report of every problem
+// found to stdout. Returns the exit status, which is non-zero if any problem was
+// found, or the specifications could not be linted.
+func lintSpecifications(cfg *config.Config) int {
+	suite, failures := spec.NewLoader(cfg).LoadSuite(true)
+
+	report := lint.NewReport(suite, failures)
+	if err := report.Write(os.Stdout, cfg.LintFormat); err != nil {
+		logger.Errorf(nil, "Lint failed: %s", err)
+		return 2
+	}
+
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
@@ -18,6 +18,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package config
 
 import (
+	"os"
 	"reflect"
 	"strings"
 
@@ -25,8 +26,10 @@ import (
 	"github.com/ian-kent/gofigure"
 )
 
-type config struct {
+// Config is the configuration of DapperDox
+type Config struct {
 	gofigure           interface{} `order:"env,flag"`
+	ConfigFile         string      `env:"CONFIG_FILE" flag:"config" flagDesc:"A YAML or TOML configuration file. Settings given in the environment or on the command line override those in the file."`
 	BindAddr           string      `env:"BIND_ADDR" flag:"bind-addr" flagDesc:"Bind address"`
 	AssetsDir          string      `env:"ASSETS_DIR" flag:"assets-dir" flagDesc:"Assets to serve. Effectively the document root."`
 	DefaultAssetsDir   string      `env:"DEFAULT_ASSETS_DIR" flag:"default-assets-dir" flagDesc:"Default assets."`
@@ -43,40 +46,72 @@ type config struct {
 	ProxyPath          []string    `env:"PROXY_PATH" flag:"proxy-path" flagDesc:"Give a path to proxy though to another service. May be multiply defined. Format is local-path=scheme://host/dst-path."`
 	TLSCertificate     string      `env:"TLS_CERTIFICATE" flag:"tls-certificate" flagDesc:"The fully qualified path to the TLS certificate file. For HTTP over TLS (HTTPS) both a certificate and a key must be provided."`
 	TLSKey             string      `env:"TLS_KEY" flag:"tls-key" flagDesc:"The fully qualified path to the TLS private key file. For HTTP over TLS (HTTPS) both a certificate and a key must be provided."`
+	ExportDir          string      `env:"EXPORT_DIR" flag:"export-dir" flagDesc:"Export the documentation as a static site into this directory, and exit rather than serving it."`
+	Watch              bool        `env:"WATCH" flag:"watch" flagDesc:"Watch the specification, assets and theme directories for changes, reloading the documentation without restarting the server."`
+	LintFormat         string      `env:"LINT_FORMAT" flag:"lint-format" flagDesc:"Report format of the lint command. One of text, json or junit."`
+	ProxyValidate      bool        `env:"PROXY_VALIDATE" flag:"proxy-validate" flagDesc:"Validate proxied requests against the documented parameters and body of the method called, rejecting those that do not conform with a 400 response."`
+	ProxyConformance   bool        `env:"PROXY_CONFORMANCE" flag:"proxy-conformance" flagDesc:"Check the responses to proxied requests against those documented for the method called, reporting differences in an X-DapperDox-Conformance header and at /conformance.json."`
+	MockPrefix         string      `env:"MOCK_PREFIX" flag:"mock-prefix" flagDesc:"Serve mock responses for the methods of the specifications under this path prefix. Mocking is disabled if not set."`
+	ShutdownTimeout    string      `env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" flagDesc:"On SIGTERM or SIGINT, how long to wait for requests in progress, including proxied requests, to complete before exiting. A duration such as 30s."`
+
+	specs map[string]SpecConfig // Settings of individual specifications, keyed by ID or filename
 }
 
-var cfg *config
+var cfg *Config
 
-// Get configures the application and returns the configuration
-func Get() (*config, error) {
-	if cfg != nil {
-		return cfg, nil
-	}
-
-	cfg = &config{
+// New returns a configuration with the default settings
+func New() *Config {
+	return &Config{
 		BindAddr:         "localhost:3123",
 		SpecDir:          "",
 		DefaultAssetsDir: "assets",
 		LogLevel:         "info",
 		SiteURL:          "http://localhost:3123/",
 		ShowAssets:       false,
+		LintFormat:       "text",
+		ShutdownTimeout:  "30s",
+	}
+}
+
+// Get configures the application from the configuration file, environment and
+// command line, and returns the validated configuration
+func Get() (*Config, error) {
+	if cfg != nil {
+		return cfg, nil
 	}
 
-	err := gofigure.Gofigure(cfg)
+	c := New()
+
+	err := gofigure.Gofigure(c)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(cfg.SpecFilename) == 0 {
-		cfg.SpecFilename = append(cfg.SpecFilename, "/swagger.json")
+	// Settings in the environment and on the command line override the file
+	if len(c.ConfigFile) != 0 {
+		f, err := Load(c.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		f.override(c, givenSettings(os.Args[1:]))
+		c = f
 	}
 
+	if len(c.SpecFilename) == 0 {
+		c.SpecFilename = append(c.SpecFilename, "/swagger.json")
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	cfg = c
 	cfg.print()
 
 	return cfg, nil
 }
 
-func (c *config) print() {
+func (c *Config) print() {
 	logger.Println(nil, "Configuration:")
 
 	s := reflect.ValueOf(c).Elem()
@@ -99,4 +134,8 @@ func (c *config) print() {
 		}
 		logger.Printf(nil, "\t%s%s: %s\n", strings.Repeat(" ", ml-len(t.Field(i).Name)), t.Field(i).Name, f.Interface())
 	}
+
+	for _, key := range c.SpecConfigs() {
+		logger.Printf(nil, "\tSpecification %s: %+v\n", key, c.specs[key])
+	}
 }
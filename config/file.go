@@ -0,0 +1,242 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// A configuration file is written in YAML or TOML, with the settings named as their
+// command line options. Those that are lists of from=to pairs on the command line are
+// given structured sections instead.
+
+type file struct {
+	BindAddr         string           `yaml:"bind-addr" toml:"bind-addr"`
+	SiteURL          string           `yaml:"site-url" toml:"site-url"`
+	LogLevel         string           `yaml:"log-level" toml:"log-level"`
+	AssetsDir        string           `yaml:"assets-dir" toml:"assets-dir"`
+	DefaultAssetsDir string           `yaml:"default-assets-dir" toml:"default-assets-dir"`
+	ForceSpecList    bool             `yaml:"force-specification-list" toml:"force-specification-list"`
+	ShowAssets       bool             `yaml:"author-show-assets" toml:"author-show-assets"`
+	ExportDir        string           `yaml:"export-dir" toml:"export-dir"`
+	Watch            bool             `yaml:"watch" toml:"watch"`
+	LintFormat       string           `yaml:"lint-format" toml:"lint-format"`
+	MockPrefix       string           `yaml:"mock-prefix" toml:"mock-prefix"`
+	ShutdownTimeout  string           `yaml:"shutdown-timeout" toml:"shutdown-timeout"`
+	Specs            specsSection     `yaml:"specs" toml:"specs"`
+	Documents        documentsSection `yaml:"documents" toml:"documents"`
+	Proxy            proxySection     `yaml:"proxy" toml:"proxy"`
+	TLS              tlsSection       `yaml:"tls" toml:"tls"`
+	Theme            themeSection     `yaml:"theme" toml:"theme"`
+
+	Specifications map[string]specSection `yaml:"specifications" toml:"specifications"`
+}
+
+type specsSection struct {
+	Dir         string    `yaml:"dir" toml:"dir"`
+	Files       []string  `yaml:"files" toml:"files"`
+	RewriteURLs []Rewrite `yaml:"rewrite-urls" toml:"rewrite-urls"`
+}
+
+type documentsSection struct {
+	RewriteURLs []Rewrite `yaml:"rewrite-urls" toml:"rewrite-urls"`
+}
+
+type proxySection struct {
+	Paths       []Proxy `yaml:"paths" toml:"paths"`
+	Validate    bool    `yaml:"validate" toml:"validate"`
+	Conformance bool    `yaml:"conformance" toml:"conformance"`
+}
+
+type tlsSection struct {
+	Certificate string `yaml:"certificate" toml:"certificate"`
+	Key         string `yaml:"key" toml:"key"`
+}
+
+type themeSection struct {
+	Name string `yaml:"name" toml:"name"`
+	Dir  string `yaml:"dir" toml:"dir"`
+}
+
+// Settings of a specification, keyed by its ID or filename
+type specSection struct {
+	Theme       themeSection     `yaml:"theme" toml:"theme"`
+	GuidesDir   string           `yaml:"guides-dir" toml:"guides-dir"`
+	RewriteURLs []Rewrite        `yaml:"rewrite-urls" toml:"rewrite-urls"`
+	Documents   documentsSection `yaml:"documents" toml:"documents"`
+	Proxy       struct {
+		Paths []Proxy `yaml:"paths" toml:"paths"`
+	} `yaml:"proxy" toml:"proxy"`
+}
+
+// -----------------------------------------------------------------------------
+// Load returns the default configuration, with the settings of the configuration
+// file given applied. Files with a .toml extension are read as TOML, and any other
+// as YAML.
+func Load(filename string) (*Config, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+
+	if strings.ToLower(filepath.Ext(filename)) == ".toml" {
+		md, err := toml.Decode(string(b), &f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("%s: unknown setting %s", filename, undecoded[0])
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil && err != io.EOF { // An empty file has no settings
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+
+	c := New()
+	f.apply(c)
+	return c, nil
+}
+
+// -----------------------------------------------------------------------------
+// Applies the settings given in a configuration file. Rewrites and proxied paths
+// are held as from=to pairs, just as when given on the command line.
+func (f *file) apply(c *Config) {
+	set := func(setting *string, value string) {
+		if value != "" {
+			*setting = value
+		}
+	}
+
+	set(&c.BindAddr, f.BindAddr)
+	set(&c.SiteURL, f.SiteURL)
+	set(&c.LogLevel, f.LogLevel)
+	set(&c.AssetsDir, f.AssetsDir)
+	set(&c.DefaultAssetsDir, f.DefaultAssetsDir)
+	set(&c.ExportDir, f.ExportDir)
+	set(&c.LintFormat, f.LintFormat)
+	set(&c.MockPrefix, f.MockPrefix)
+	set(&c.ShutdownTimeout, f.ShutdownTimeout)
+	set(&c.SpecDir, f.Specs.Dir)
+	set(&c.TLSCertificate, f.TLS.Certificate)
+	set(&c.TLSKey, f.TLS.Key)
+	set(&c.Theme, f.Theme.Name)
+	set(&c.ThemeDir, f.Theme.Dir)
+
+	c.ForceSpecList = f.ForceSpecList
+	c.ShowAssets = f.ShowAssets
+	c.Watch = f.Watch
+	c.ProxyValidate = f.Proxy.Validate
+	c.ProxyConformance = f.Proxy.Conformance
+
+	c.SpecFilename = f.Specs.Files
+
+	for _, rw := range f.Specs.RewriteURLs {
+		c.SpecRewriteURL = append(c.SpecRewriteURL, rw.String())
+	}
+	for _, rw := range f.Documents.RewriteURLs {
+		c.DocumentRewriteURL = append(c.DocumentRewriteURL, rw.String())
+	}
+	for _, p := range f.Proxy.Paths {
+		c.ProxyPath = append(c.ProxyPath, p.String())
+	}
+
+	for key, s := range f.Specifications {
+		sc := SpecConfig{
+			Theme:     s.Theme.Name,
+			ThemeDir:  s.Theme.Dir,
+			GuidesDir: s.GuidesDir,
+		}
+		for _, rw := range s.RewriteURLs {
+			sc.SpecRewriteURL = append(sc.SpecRewriteURL, rw.String())
+		}
+		for _, rw := range s.Documents.RewriteURLs {
+			sc.DocumentRewriteURL = append(sc.DocumentRewriteURL, rw.String())
+		}
+		for _, p := range s.Proxy.Paths {
+			sc.ProxyPath = append(sc.ProxyPath, p.String())
+		}
+		c.SetSpecConfig(key, sc)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Overrides the settings of c with those of o named in set.
+func (c *Config) override(o *Config, set map[string]bool) {
+	s := reflect.ValueOf(c).Elem()
+	v := reflect.ValueOf(o).Elem()
+	t := s.Type()
+
+	for i := 0; i < s.NumField(); i++ {
+		if s.Field(i).CanSet() && set[t.Field(i).Name] {
+			s.Field(i).Set(v.Field(i))
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Returns the names of the settings given in the environment, or in the command
+// line arguments args, by the variables and options named in their env and flag tags.
+// A setting given its default value is still given, so overrides the file.
+func givenSettings(args []string) map[string]bool {
+	flags := make(map[string]bool)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+		flags[name] = true
+	}
+
+	set := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if env := field.Tag.Get("env"); env != "" {
+			if _, ok := os.LookupEnv(env); ok {
+				set[field.Name] = true
+			}
+		}
+		if flag := field.Tag.Get("flag"); flag != "" && flags[flag] {
+			set[field.Name] = true
+		}
+	}
+	return set
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,134 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// SpecConfig holds the settings a specification may be given of its own, overriding
+// those of the configuration. Settings that are not given are those of the
+// configuration, and lists given replace those of the configuration.
+type SpecConfig struct {
+	Theme              string   // Theme to render the documentation of the specification
+	ThemeDir           string   // Directory containing the theme
+	GuidesDir          string   // Directory of the specification's guides
+	SpecRewriteURL     []string // URLs to be rewritten in the specification, as from=to pairs
+	DocumentRewriteURL []string // URLs to be rewritten in the specification's documentation
+	ProxyPath          []string // Paths proxied, and checked against the specification
+}
+
+// -----------------------------------------------------------------------------
+// SetSpecConfig configures a specification, identified by its ID or by its
+// spec-filename, with settings of its own.
+func (c *Config) SetSpecConfig(key string, sc SpecConfig) {
+	// Copied, so that copies of the configuration are unchanged
+	specs := make(map[string]SpecConfig, len(c.specs)+1)
+	for k, v := range c.specs {
+		specs[k] = v
+	}
+	specs[key] = sc
+	c.specs = specs
+}
+
+// -----------------------------------------------------------------------------
+// SpecConfigs returns the keys of the specifications configured with settings of
+// their own, in order.
+func (c *Config) SpecConfigs() []string {
+	var keys []string
+	for key := range c.specs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// -----------------------------------------------------------------------------
+// SpecConfig returns the settings of the specification with the ID and location
+// given. Settings configured for its ID take precedence over those for its filename.
+func (c *Config) SpecConfig(id string, location string) (SpecConfig, bool) {
+	var sc SpecConfig
+	found := false
+
+	keys := []string{location, strings.TrimPrefix(location, "/"), id}
+	if !strings.HasPrefix(location, "/") {
+		keys[1] = "/" + location
+	}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if s, ok := c.specs[key]; ok {
+			sc.merge(s)
+			found = true
+		}
+	}
+	return sc, found
+}
+
+func (sc *SpecConfig) merge(s SpecConfig) {
+	set := func(setting *string, value string) {
+		if value != "" {
+			*setting = value
+		}
+	}
+	set(&sc.Theme, s.Theme)
+	set(&sc.ThemeDir, s.ThemeDir)
+	set(&sc.GuidesDir, s.GuidesDir)
+
+	if s.SpecRewriteURL != nil {
+		sc.SpecRewriteURL = s.SpecRewriteURL
+	}
+	if s.DocumentRewriteURL != nil {
+		sc.DocumentRewriteURL = s.DocumentRewriteURL
+	}
+	if s.ProxyPath != nil {
+		sc.ProxyPath = s.ProxyPath
+	}
+}
+
+// -----------------------------------------------------------------------------
+// ForSpec returns the configuration of the specification with the ID and location
+// given. This is c itself, unless the specification has settings of its own.
+func (c *Config) ForSpec(id string, location string) *Config {
+	sc, ok := c.SpecConfig(id, location)
+	if !ok {
+		return c
+	}
+
+	s := *c
+	if sc.Theme != "" {
+		s.Theme = sc.Theme
+	}
+	if sc.ThemeDir != "" {
+		s.ThemeDir = sc.ThemeDir
+	}
+	if sc.SpecRewriteURL != nil {
+		s.SpecRewriteURL = sc.SpecRewriteURL
+	}
+	if sc.DocumentRewriteURL != nil {
+		s.DocumentRewriteURL = sc.DocumentRewriteURL
+	}
+	if sc.ProxyPath != nil {
+		s.ProxyPath = sc.ProxyPath
+	}
+	return &s
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,212 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// Rewrite is a URL to be rewritten as another, in the specifications or documents.
+type Rewrite struct {
+	From string `yaml:"from" toml:"from"`
+	To   string `yaml:"to" toml:"to"`
+}
+
+// Proxy is a local path proxied through to another service.
+type Proxy struct {
+	Path   string `yaml:"path" toml:"path"`
+	Target string `yaml:"target" toml:"target"`
+}
+
+// String returns the rewrite as a from=to pair.
+func (rw Rewrite) String() string {
+	if rw.To == "" {
+		return rw.From
+	}
+	return rw.From + "=" + rw.To
+}
+
+// String returns the proxied path as a local-path=target pair.
+func (p Proxy) String() string {
+	return p.Path + "=" + p.Target
+}
+
+// -----------------------------------------------------------------------------
+// SpecRewrites returns the configured spec-rewrite-url replacements. URLs given
+// without a replacement are rewritten as the site-url.
+func (c *Config) SpecRewrites() []Rewrite {
+	var rewrites []Rewrite
+	for _, s := range c.SpecRewriteURL {
+		rw := parseRewrite(s)
+		if rw.To == "" && !strings.Contains(s, "=") {
+			rw.To = c.SiteURL
+		}
+		rewrites = append(rewrites, rw)
+	}
+	return rewrites
+}
+
+// -----------------------------------------------------------------------------
+// DocumentRewrites returns the configured document-rewrite-url replacements.
+func (c *Config) DocumentRewrites() []Rewrite {
+	var rewrites []Rewrite
+	for _, s := range c.DocumentRewriteURL {
+		rewrites = append(rewrites, parseRewrite(s))
+	}
+	return rewrites
+}
+
+// -----------------------------------------------------------------------------
+// Proxies returns the configured proxied paths.
+func (c *Config) Proxies() []Proxy {
+	var proxies []Proxy
+	for _, s := range c.ProxyPath {
+		slice := strings.SplitN(s, "=", 2)
+		p := Proxy{Path: slice[0]}
+		if len(slice) == 2 {
+			p.Target = slice[1]
+		}
+		proxies = append(proxies, p)
+	}
+	return proxies
+}
+
+func parseRewrite(s string) Rewrite {
+	slice := strings.SplitN(s, "=", 2)
+	rw := Rewrite{From: slice[0]}
+	if len(slice) == 2 {
+		rw.To = slice[1]
+	}
+	return rw
+}
+
+// -----------------------------------------------------------------------------
+// Validate checks the configuration, returning an error that describes every
+// setting that is not valid.
+func (c *Config) Validate() error {
+	var problems []string
+
+	invalid := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, err := logger.LevelFromString(c.LogLevel); err != nil {
+		invalid("log-level: %s", err)
+	}
+	switch c.LintFormat {
+	case "text", "json", "junit":
+	default:
+		invalid("lint-format: expected text|json|junit, got '%s'", c.LintFormat)
+	}
+	if u, err := url.Parse(c.SiteURL); err != nil || u.Scheme == "" || u.Host == "" {
+		invalid("site-url: '%s' is not an absolute URL", c.SiteURL)
+	}
+	if c.MockPrefix != "" && !strings.HasPrefix(c.MockPrefix, "/") {
+		invalid("mock-prefix: '%s' is not a path beginning with /", c.MockPrefix)
+	}
+	if d, err := time.ParseDuration(c.ShutdownTimeout); err != nil || d < 0 {
+		invalid("shutdown-timeout: '%s' is not a duration, such as 30s", c.ShutdownTimeout)
+	}
+
+	c.validateSettings(invalid)
+
+	// Settings of individual specifications are checked in the same way
+	for _, key := range c.SpecConfigs() {
+		sc := c.specs[key]
+		s := &Config{
+			SiteURL:            c.SiteURL,
+			ThemeDir:           sc.ThemeDir,
+			SpecRewriteURL:     sc.SpecRewriteURL,
+			DocumentRewriteURL: sc.DocumentRewriteURL,
+			ProxyPath:          sc.ProxyPath,
+		}
+		invalidSpec := func(format string, args ...interface{}) {
+			invalid("specification %s: "+format, append([]interface{}{key}, args...)...)
+		}
+		s.validateSettings(invalidSpec)
+		validateDir(invalidSpec, "guides-dir", sc.GuidesDir)
+	}
+
+	if (c.TLSCertificate == "") != (c.TLSKey == "") {
+		invalid("tls-certificate, tls-key: both a certificate and a key must be given to enable TLS")
+	}
+	for _, f := range []struct{ setting, path string }{
+		{"tls-certificate", c.TLSCertificate},
+		{"tls-key", c.TLSKey},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			invalid("%s: %s", f.setting, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Checks the directories, rewrites and proxied paths configured, which may also be
+// given to individual specifications.
+func (c *Config) validateSettings(invalid func(format string, args ...interface{})) {
+	validateDir(invalid, "spec-dir", c.SpecDir)
+	validateDir(invalid, "assets-dir", c.AssetsDir)
+	validateDir(invalid, "theme-dir", c.ThemeDir)
+
+	for i, rw := range c.SpecRewrites() {
+		if rw.From == "" {
+			invalid("spec-rewrite-url: '%s' does not give a URL to rewrite", c.SpecRewriteURL[i])
+		}
+	}
+	for i, rw := range c.DocumentRewrites() {
+		if rw.From == "" || rw.To == "" {
+			invalid("document-rewrite-url: '%s' is not a from=to pair", c.DocumentRewriteURL[i])
+		}
+	}
+	for i, p := range c.Proxies() {
+		if !strings.HasPrefix(p.Path, "/") {
+			invalid("proxy-path: '%s' is not a local-path=scheme://host/dst-path pair", c.ProxyPath[i])
+			continue
+		}
+		if u, err := url.Parse(p.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("proxy-path: '%s' does not proxy to an http or https URL", c.ProxyPath[i])
+		}
+	}
+}
+
+func validateDir(invalid func(format string, args ...interface{}), setting string, dir string) {
+	if dir == "" {
+		return
+	}
+	if info, err := os.Stat(dir); err != nil {
+		invalid("%s: %s", setting, err)
+	} else if !info.IsDir() {
+		invalid("%s: %s is not a directory", setting, dir)
+	}
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,287 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package export
+
+// This package exports the documentation as a static site. Every GET route registered
+// with the router is requested in-process, and the response written to a directory
+// tree. HTML pages are written as <route>/index.html, everything else (stylesheets,
+// scripts, images and specifications) to the route path itself. Site-absolute links in
+// the exported pages are rewritten as relative links, so that the exported site can be
+// hosted from any location on a plain file server or object store, or browsed directly
+// from the filesystem.
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/pat"
+)
+
+// Site-absolute links in HTML attributes, and in CSS url() declarations
+var htmlLink = regexp.MustCompile(`((?:href|src|action)=["'])(/[^"'#?]*)([?#][^"']*)?(["'])`)
+var cssLink = regexp.MustCompile(`(url\(["']?)(/[^)"'#?]*)([?#][^)"']*)?(["']?\))`)
+
+// Routes of JSON endpoints that are not pages of the documentation, and would be
+// meaningless as a snapshot
+var nonPages = map[string]bool{
+	"/search.json":      true,
+	"/conformance.json": true,
+}
+
+type page struct {
+	route       string
+	file        string
+	contentType string
+	body        []byte
+	redirect    string
+}
+
+// ---------------------------------------------------------------------------
+// Site renders every GET route registered with router, writing the static site
+// into dir. Routes beneath any of the path prefixes excluded, such as that of the mock
+// responses, are not pages so are not exported.
+func Site(router *pat.Router, dir string, excluded ...string) error {
+
+	logger.Infof(nil, "Exporting static site to %s", dir)
+
+	routes, err := getRoutes(router, excluded)
+	if err != nil {
+		return err
+	}
+
+	pages := make(map[string]*page)
+
+	for _, route := range routes {
+		p := renderRoute(router, route)
+		if p != nil {
+			pages[route] = p
+		}
+	}
+
+	nestFiles(pages)
+
+	for _, p := range pages {
+		if err := writePage(dir, p, pages); err != nil {
+			return err
+		}
+	}
+
+	logger.Infof(nil, "Exported %d pages and files", len(pages))
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Returns the path of every GET route that does not contain variables, and is not a
+// JSON endpoint or beneath an excluded prefix, in order.
+func getRoutes(router *pat.Router, excluded []string) ([]string, error) {
+
+	var routes []string
+
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil // Not a path route
+		}
+		if strings.Contains(tmpl, "{") {
+			logger.Debugf(nil, "- Skipping parameterised route %s", tmpl)
+			return nil
+		}
+		if methods, err := route.GetMethods(); err == nil && !contains(methods, "GET") {
+			return nil
+		}
+		if nonPages[tmpl] || isExcluded(tmpl, excluded) {
+			logger.Debugf(nil, "- Skipping %s, not a page", tmpl)
+			return nil
+		}
+		routes = append(routes, tmpl)
+		return nil
+	})
+
+	sort.Strings(routes)
+	return routes, err
+}
+
+// ---------------------------------------------------------------------------
+
+func isExcluded(route string, excluded []string) bool {
+	for _, prefix := range excluded {
+		if prefix != "" && (route == prefix || strings.HasPrefix(route, strings.TrimSuffix(prefix, "/")+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+
+func renderRoute(router *pat.Router, route string) *page {
+
+	req := httptest.NewRequest("GET", route, nil)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	p := &page{
+		route:       route,
+		contentType: rec.Header().Get("Content-Type"),
+		body:        rec.Body.Bytes(),
+	}
+
+	switch {
+	case rec.Code == http.StatusOK:
+	case rec.Code >= 300 && rec.Code < 400:
+		p.redirect = rec.Header().Get("Location")
+		p.contentType = "text/html"
+	default:
+		// Routes registered with a PathPrefix, such as proxied paths, are not pages.
+		logger.Debugf(nil, "- Skipping %s, status %d", route, rec.Code)
+		return nil
+	}
+
+	if isHTML(p.contentType) {
+		p.file = path.Join(route, "index.html")
+	} else {
+		p.file = route
+	}
+	logger.Debugf(nil, "- %s -> %s", route, p.file)
+
+	return p
+}
+
+// ---------------------------------------------------------------------------
+// A file cannot also be the directory of other files, as the JSON API routes
+// nested beneath one another would be. Such files are written within that
+// directory instead, as index.<ext>.
+func nestFiles(pages map[string]*page) {
+
+	dirs := make(map[string]bool)
+	for _, p := range pages {
+		for dir := path.Dir(p.file); dir != "/" && dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	for _, p := range pages {
+		if !dirs[p.file] {
+			continue
+		}
+		ext := path.Ext(p.route)
+		if strings.Contains(p.contentType, "json") {
+			ext = ".json"
+		}
+		p.file = path.Join(p.route, "index"+ext)
+		logger.Debugf(nil, "- %s -> %s", p.route, p.file)
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func writePage(dir string, p *page, pages map[string]*page) error {
+
+	body := p.body
+
+	if p.redirect != "" {
+		target, ok := pages[stripQuery(p.redirect)]
+		if !ok {
+			logger.Debugf(nil, "- Skipping redirect from %s to %s, which has not been exported", p.route, p.redirect)
+			return nil
+		}
+		link := html.EscapeString(relativeLink(p.file, target.file))
+		body = []byte(fmt.Sprintf("<!DOCTYPE html>\n<html><head><meta http-equiv=\"refresh\" content=\"0; url=%s\"></head><body><a href=\"%s\">%s</a></body></html>\n", link, link, link))
+	} else if isHTML(p.contentType) {
+		body = rewriteLinks(htmlLink, body, p, pages)
+	} else if strings.HasPrefix(p.contentType, "text/css") {
+		body = rewriteLinks(cssLink, body, p, pages)
+	}
+
+	file := filepath.Join(dir, filepath.FromSlash(p.file))
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, body, 0644)
+}
+
+// ---------------------------------------------------------------------------
+// Rewrites the site-absolute links matched by re. Links to routes that have not
+// been exported are left alone. Query strings are dropped, as they have no meaning
+// on a static site.
+func rewriteLinks(re *regexp.Regexp, body []byte, p *page, pages map[string]*page) []byte {
+
+	return re.ReplaceAllFunc(body, func(match []byte) []byte {
+		// Submatches are: prefix, link path, query and/or fragment, closing quote
+		m := re.FindSubmatch(match)
+
+		target, ok := pages[string(m[2])]
+		if !ok {
+			return match
+		}
+
+		fragment := ""
+		if i := strings.Index(string(m[3]), "#"); i >= 0 {
+			fragment = string(m[3][i:])
+		}
+
+		return []byte(string(m[1]) + relativeLink(p.file, target.file) + fragment + string(m[4]))
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Returns the link from the file from to the file to, both being site-absolute.
+func relativeLink(from string, to string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(from)), filepath.FromSlash(to))
+	if err != nil {
+		return to
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ---------------------------------------------------------------------------
+
+func stripQuery(link string) string {
+	if i := strings.IndexAny(link, "?#"); i >= 0 {
+		return link[:i]
+	}
+	return link
+}
+
+func isHTML(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/html")
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
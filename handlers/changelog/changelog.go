@@ -0,0 +1,82 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package changelog
+
+import (
+	"net/http"
+
+	"github.com/dapperdox/dapperdox/changelog"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// ----------------------------------------------------------------------------------------
+// Register creates a changelog route for each specification that has earlier revisions.
+func Register(r *pat.Router, rnd *render.Renderer, suite map[string]*spec.APISpecification) {
+	logger.Infof(nil, "Registering changelogs")
+
+	for _, specification := range suite {
+		if len(specification.Revisions) == 0 {
+			continue
+		}
+		logger.Tracef(nil, "+ Changelog for specification '%s'", specification.ID)
+
+		r.Path("/" + specification.ID + "/changelog").Methods("GET").HandlerFunc(changelogHandler(rnd.For(specification), specification))
+		r.Path("/" + specification.ID + "/changelog.json").Methods("GET").HandlerFunc(changelogJSONHandler(rnd, specification))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+func changelogHandler(rnd *render.Renderer, specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		cl := compare(specification, req)
+
+		logger.Tracef(req, "Render HTML for changelog of %s from %s to %s", specification.ID, cl.From, cl.To)
+
+		rnd.HTML(w, http.StatusOK, "changelog", rnd.DefaultVars(req, specification, render.Vars{"Title": "Changelog", "Changelog": cl}))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+func changelogJSONHandler(rnd *render.Renderer, specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rnd.JSON(w, http.StatusOK, compare(specification, req))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// Compares the specification with the revision given by the from parameter, which is
+// a version. The previous revision is compared with if none is given, or no revision
+// has the version.
+func compare(specification *spec.APISpecification, req *http.Request) *changelog.Changelog {
+	from := specification.Revisions[len(specification.Revisions)-1]
+
+	if version := req.URL.Query().Get("from"); version != "" {
+		for _, revision := range specification.Revisions {
+			if revision.Version == version {
+				from = revision
+			}
+		}
+	}
+	return changelog.Compare(from, specification)
+}
+
+// ----------------------------------------------------------------------------------------
+// end
@@ -35,25 +35,25 @@ import (
 
 // ---------------------------------------------------------------------------
 // Register routes for guide pages
-func Register(r *pat.Router) {
+func Register(r *pat.Router, rnd *render.Renderer, suite map[string]*spec.APISpecification) {
 
 	logger.Infof(nil, "Registering guides")
 
 	// specification specific guides
-	for _, specification := range spec.APISuite {
+	for _, specification := range suite {
 		logger.Debugf(nil, "- Specification guides for '%s'", specification.APIInfo.Title)
-		register(r, "assets/templates", specification)
+		register(r, rnd.For(specification), "assets/templates", specification)
 	}
 
 	// Top level guides
 	logger.Debugf(nil, "- Root guides")
-	register(r, "assets/templates", nil)
+	register(r, rnd, "assets/templates", nil)
 
 	logger.Debugf(nil, "\n")
 }
 
 // ---------------------------------------------------------------------------
-func register(r *pat.Router, base string, specification *spec.APISpecification) {
+func register(r *pat.Router, rnd *render.Renderer, base string, specification *spec.APISpecification) {
 
 	root_node := "/guides"
 	route_base := "/guides"
@@ -71,7 +71,7 @@ func register(r *pat.Router, base string, specification *spec.APISpecification)
 
 	logger.Tracef(nil, "  - Walk compiled asset tree %s", path_base)
 
-	for _, path := range asset.AssetNames() {
+	for _, path := range rnd.Assets().AssetNames() {
 		if !strings.HasPrefix(path, path_base) { // Only keep assets we want
 			continue
 		}
@@ -88,7 +88,7 @@ func register(r *pat.Router, base string, specification *spec.APISpecification)
 
 			logger.Tracef(nil, "      = URL  "+route)
 
-			buildNavigation(guidesNavigation, path, path_base, route, ext)
+			buildNavigation(rnd.Assets(), guidesNavigation, path, path_base, route, ext)
 
 			r.Path(route).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 				sid := "TOP LEVEL"
@@ -96,7 +96,7 @@ func register(r *pat.Router, base string, specification *spec.APISpecification)
 					sid = specification.ID
 				}
 				logger.Tracef(nil, "Fetching guide from '%s' for spec ID %s\n", resource, sid)
-				render.HTML(w, http.StatusOK, resource, render.DefaultVars(req, specification, render.Vars{"Guide": resource}))
+				rnd.HTML(w, http.StatusOK, resource, rnd.DefaultVars(req, specification, render.Vars{"Guide": resource}))
 			})
 		}
 	}
@@ -111,7 +111,7 @@ func register(r *pat.Router, base string, specification *spec.APISpecification)
 	})
 
 	// Register the guides navigation with the renderer
-	render.SetGuidesNavigation(specification, &guidesNavigation.Children)
+	rnd.SetGuidesNavigation(specification, &guidesNavigation.Children)
 }
 
 // ---------------------------------------------------------------------------
@@ -168,13 +168,13 @@ func StripBasepathAndExtension(name string, basepath string) string {
 }
 
 // ---------------------------------------------------------------------------
-func buildNavigation(nav *navigation.NavigationNode, path string, path_base string, route string, ext string) {
+func buildNavigation(assets *asset.Store, nav *navigation.NavigationNode, path string, path_base string, route string, ext string) {
 
 	logger.Tracef(nil, "      - Look for metadata asset %s\n", path)
 
 	// See if guide has been marked up with nagivation metadata...
-	hierarchy := asset.MetaData(path, "Navigation")
-	sortOrder := asset.MetaData(path, "SortOrder")
+	hierarchy := assets.MetaData(path, "Navigation")
+	sortOrder := assets.MetaData(path, "SortOrder")
 
 	if len(hierarchy) > 0 {
 		logger.Tracef(nil, "      * Got navigation metadata %s for file %s\n", hierarchy, path)
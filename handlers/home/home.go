@@ -29,18 +29,18 @@ import (
 
 // ----------------------------------------------------------------------------------------
 // Register creates routes for each home handler
-func Register(r *pat.Router) {
+func Register(r *pat.Router, cfg *config.Config, rnd *render.Renderer, suite map[string]*spec.APISpecification, failures map[string]*spec.APISpecification) {
 	logger.Debugln(nil, "registering handlers for home page")
 
 	count := 0
 	// Homepages for each loaded specification
 	var specification *spec.APISpecification // Ends up being populated with the last spec processed
 
-	for _, specification = range spec.APISuite {
+	for _, specification = range suite {
 
 		logger.Tracef(nil, "Build homepage route for specification '%s'", specification.ID)
 
-		r.Path("/" + specification.ID + "/reference").Methods("GET").HandlerFunc(specificationSummaryHandler(specification))
+		r.Path("/" + specification.ID + "/reference").Methods("GET").HandlerFunc(specificationSummaryHandler(rnd.For(specification), specification))
 
 		// If missingh trailing slash, redirect to add it
 		r.Path("/" + specification.ID).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -50,29 +50,38 @@ func Register(r *pat.Router) {
 		count++
 	}
 
-	cfg, _ := config.Get()
+	// Specifications that failed to load have an error page listing their diagnostics,
+	// in place of their documentation.
+	for _, failure := range failures {
+		logger.Tracef(nil, "Build error page route for specification '%s'", failure.ID)
 
-	if count == 1 && cfg.ForceSpecList == false {
+		r.Path("/" + failure.ID).Methods("GET").HandlerFunc(specificationFailureHandler(rnd, failure))
+		r.PathPrefix("/" + failure.ID + "/").Methods("GET").HandlerFunc(specificationFailureHandler(rnd, failure))
+	}
+
+	if count == 1 && len(failures) == 0 && cfg.ForceSpecList == false {
 		// If there is only one specification loaded, then hotwire '/' to redirect to the
 		// specification summary page unless DapperDox is configured to show the specification list page.
 		r.Path("/").Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			http.Redirect(w, req, "/"+specification.ID+"/reference", 302)
 		})
 	} else {
-		r.Path("/").Methods("GET").HandlerFunc(specificationListHandler)
+		r.Path("/").Methods("GET").HandlerFunc(specificationListHandler(rnd))
 	}
 }
 
 // ----------------------------------------------------------------------------------------
 // Handler is a http.Handler for the specification list page
-func specificationListHandler(w http.ResponseWriter, req *http.Request) {
-	logger.Tracef(nil, "Render HTML for top level index page")
+func specificationListHandler(rnd *render.Renderer) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger.Tracef(nil, "Render HTML for top level index page")
 
-	render.HTML(w, http.StatusOK, "specification_list", render.DefaultVars(req, nil, render.Vars{"Title": "Specifications list", "SpecificationList": true}))
+		rnd.HTML(w, http.StatusOK, "specification_list", rnd.DefaultVars(req, nil, render.Vars{"Title": "Specifications list", "SpecificationList": true}))
+	}
 }
 
 // ----------------------------------------------------------------------------------------
-func specificationSummaryHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+func specificationSummaryHandler(rnd *render.Renderer, specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
 
 	// The default "theme" level reference index page.
 	tmpl := "specification_summary"
@@ -81,11 +90,22 @@ func specificationSummaryHandler(specification *spec.APISpecification) func(w ht
 
 	logger.Tracef(nil, "+ Test for template '%s'", customTmpl)
 
-	if render.TemplateLookup(customTmpl) != nil {
+	if rnd.TemplateLookup(customTmpl) != nil {
 		tmpl = customTmpl
 	}
 	return func(w http.ResponseWriter, req *http.Request) {
-		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": "Specification summary", "SpecificationSummary": true}))
+		rnd.HTML(w, http.StatusOK, tmpl, rnd.DefaultVars(req, specification, render.Vars{"Title": "Specification summary", "SpecificationSummary": true}))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+func specificationFailureHandler(rnd *render.Renderer, specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rnd.HTML(w, http.StatusInternalServerError, "error", rnd.DefaultVars(req, nil, render.Vars{
+			"code":        http.StatusInternalServerError,
+			"error":       "Specification " + specification.URL + " failed to load",
+			"Diagnostics": specification.Diagnostics,
+		}))
 	}
 }
 
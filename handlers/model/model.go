@@ -0,0 +1,131 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package model
+
+// This package serves the documentation model, as compiled from the loaded
+// specifications, as a read-only JSON API:
+//
+//   /_api/specs                                       The specifications
+//   /_api/specs/{id}                                  A specification, with its APIs, resources and security schemes
+//   /_api/specs/{id}/apis                             The APIs of a specification
+//   /_api/specs/{id}/apis/{api}                       An API, with its methods
+//   /_api/specs/{id}/apis/{api}/methods/{method}      A method
+//   /_api/specs/{id}/resources                        The resources of a specification
+//   /_api/specs/{id}/resources/{resource}             A resource
+//   /_api/specs/{id}/security                         The security schemes of a specification
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+const prefix = "/_api"
+
+// ----------------------------------------------------------------------------------------
+// Register creates the JSON API routes for the loaded specifications.
+func Register(r *pat.Router, rnd *render.Renderer, suite map[string]*spec.APISpecification) {
+	logger.Infof(nil, "Registering JSON API")
+
+	var ids []string
+	for id := range suite {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var list []Specification
+	for _, id := range ids {
+		specification := suite[id]
+		list = append(list, specificationSummary(specification))
+		registerSpecification(r, rnd, specification)
+	}
+	if list == nil {
+		list = []Specification{}
+	}
+
+	r.Path(prefix + "/specs").Methods("GET").HandlerFunc(jsonHandler(rnd, list))
+
+	// Anything else under the prefix is answered in JSON too
+	r.PathPrefix(prefix + "/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rnd.JSON(w, http.StatusNotFound, map[string]string{"error": "Not found"})
+	})
+}
+
+// ----------------------------------------------------------------------------------------
+
+func registerSpecification(r *pat.Router, rnd *render.Renderer, specification *spec.APISpecification) {
+	logger.Tracef(nil, "+ JSON API for specification '%s'", specification.ID)
+
+	base := specHref(specification)
+
+	r.Path(base).Methods("GET").HandlerFunc(jsonHandler(rnd, specificationView(specification)))
+
+	apis := []API{}
+	registered := make(map[string]bool)
+
+	for i := range specification.APIs {
+		api := &specification.APIs[i]
+		view := apiView(specification, api)
+		apis = append(apis, view)
+
+		r.Path(view.Href).Methods("GET").HandlerFunc(jsonHandler(rnd, view))
+
+		for j := range api.Methods {
+			method := &api.Methods[j]
+			href := methodHref(specification, api, method)
+			if registered[href] {
+				continue // Method IDs are checked when loading, and only one is documented
+			}
+			registered[href] = true
+			r.Path(href).Methods("GET").HandlerFunc(jsonHandler(rnd, methodView(specification, api, method)))
+		}
+	}
+	r.Path(base + "/apis").Methods("GET").HandlerFunc(jsonHandler(rnd, apis))
+
+	resources := currentResources(specification)
+	list := []Link{}
+	for _, id := range resourceIDs(resources) {
+		view := resourceView(specification, resources[id])
+		list = append(list, Link{ID: id, Name: view.Title, Href: view.Href})
+
+		r.Path(view.Href).Methods("GET").HandlerFunc(jsonHandler(rnd, view))
+	}
+	r.Path(base + "/resources").Methods("GET").HandlerFunc(jsonHandler(rnd, list))
+
+	schemes := make(map[string]SecurityScheme)
+	for name, scheme := range specification.SecurityDefinitions {
+		schemes[name] = securitySchemeView(scheme)
+	}
+	r.Path(base + "/security").Methods("GET").HandlerFunc(jsonHandler(rnd, schemes))
+}
+
+// ----------------------------------------------------------------------------------------
+// Views are built when registering, as the model does not change until the routes are
+// registered again.
+func jsonHandler(rnd *render.Renderer, view interface{}) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rnd.JSON(w, http.StatusOK, view)
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// end
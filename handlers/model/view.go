@@ -0,0 +1,428 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package model
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// The documentation model holds references in both directions, between methods and
+// the resources they use, and between APIs and their methods. So it is presented as
+// JSON through these views, which refer to other parts of the model by ID and link.
+
+// Nesting beyond which resource properties are not presented, in case of recursive models
+const maxDepth = 16
+
+// Specification is the view of a specification
+type Specification struct {
+	ID              string                    `json:"id"`
+	Title           string                    `json:"title"`
+	Description     string                    `json:"description,omitempty"` // HTML
+	Version         string                    `json:"version,omitempty"`
+	Href            string                    `json:"href"`
+	APIs            []API                     `json:"apis,omitempty"`
+	Resources       []Link                    `json:"resources,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// API is the view of an API group
+type API struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Version  string   `json:"version,omitempty"`
+	Href     string   `json:"href"`
+	Consumes []string `json:"consumes,omitempty"`
+	Produces []string `json:"produces,omitempty"`
+	Methods  []Link   `json:"methods,omitempty"`
+}
+
+// Link refers to a method or resource
+type Link struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Href   string `json:"href"`
+}
+
+// Method is the view of an API method
+type Method struct {
+	ID              string              `json:"id"`
+	Name            string              `json:"name"`
+	Description     string              `json:"description,omitempty"` // HTML
+	Method          string              `json:"method"`
+	Path            string              `json:"path"`
+	OperationName   string              `json:"operationName,omitempty"`
+	API             string              `json:"api"`
+	Href            string              `json:"href"`
+	Consumes        []string            `json:"consumes,omitempty"`
+	Produces        []string            `json:"produces,omitempty"`
+	Parameters      []Parameter         `json:"parameters,omitempty"`
+	Body            *Parameter          `json:"body,omitempty"`
+	Responses       []Response          `json:"responses,omitempty"`
+	DefaultResponse *Response           `json:"defaultResponse,omitempty"`
+	Security        map[string][]string `json:"security,omitempty"` // Scheme name to scopes
+	CodeSamples     map[string]string   `json:"codeSamples,omitempty"`
+	Resources       []Link              `json:"resources,omitempty"`
+}
+
+// Parameter is the view of a method parameter
+type Parameter struct {
+	Name             string   `json:"name"`
+	In               string   `json:"in"`
+	Description      string   `json:"description,omitempty"` // HTML
+	Required         bool     `json:"required"`
+	Type             []string `json:"type,omitempty"`
+	CollectionFormat string   `json:"collectionFormat,omitempty"`
+	Enum             []string `json:"enum,omitempty"`
+	Resource         *Link    `json:"resource,omitempty"` // For the request body
+	IsArray          bool     `json:"isArray,omitempty"`
+}
+
+// Response is the view of a method response
+type Response struct {
+	Status      int      `json:"status,omitempty"`      // Zero for the default response
+	Description string   `json:"description,omitempty"` // HTML
+	Resource    *Link    `json:"resource,omitempty"`
+	IsArray     bool     `json:"isArray,omitempty"`
+	Headers     []Header `json:"headers,omitempty"`
+}
+
+// Header is the view of a response header
+type Header struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Type        []string `json:"type,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Default     string   `json:"default,omitempty"`
+}
+
+// Resource is the view of a resource, or of one of its properties
+type Resource struct {
+	ID            string               `json:"id"`
+	Title         string               `json:"title,omitempty"`
+	Description   string               `json:"description,omitempty"` // HTML
+	Type          []string             `json:"type,omitempty"`
+	Required      bool                 `json:"required,omitempty"`
+	ReadOnly      bool                 `json:"readOnly,omitempty"`
+	Enum          []string             `json:"enum,omitempty"`
+	Properties    map[string]*Resource `json:"properties,omitempty"`
+	Discriminator string               `json:"discriminator,omitempty"`
+	Variants      []Variant            `json:"variants,omitempty"`
+	Example       json.RawMessage      `json:"example,omitempty"`
+	Href          string               `json:"href,omitempty"`
+	Methods       []Link               `json:"methods,omitempty"` // Methods using the resource
+}
+
+// Variant is the view of an alternative form of a polymorphic resource
+type Variant struct {
+	Name               string    `json:"name"`
+	DiscriminatorValue string    `json:"discriminatorValue,omitempty"`
+	Resource           *Resource `json:"resource,omitempty"`
+}
+
+// SecurityScheme is the view of a security scheme
+type SecurityScheme struct {
+	Type             string            `json:"type"`
+	Description      string            `json:"description,omitempty"` // HTML
+	ParamName        string            `json:"paramName,omitempty"`
+	ParamLocation    string            `json:"paramLocation,omitempty"`
+	Flow             string            `json:"flow,omitempty"`
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+func specHref(s *spec.APISpecification) string {
+	return prefix + "/specs/" + s.ID
+}
+
+func apiHref(s *spec.APISpecification, api *spec.APIGroup) string {
+	return specHref(s) + "/apis/" + api.ID
+}
+
+func methodHref(s *spec.APISpecification, api *spec.APIGroup, m *spec.Method) string {
+	return apiHref(s, api) + "/methods/" + m.ID
+}
+
+func resourceHref(s *spec.APISpecification, id string) string {
+	return specHref(s) + "/resources/" + id
+}
+
+// -----------------------------------------------------------------------------
+
+func specificationView(s *spec.APISpecification) Specification {
+	v := specificationSummary(s)
+
+	for i := range s.APIs {
+		v.APIs = append(v.APIs, apiView(s, &s.APIs[i]))
+	}
+	resources := currentResources(s)
+	for _, id := range resourceIDs(resources) {
+		v.Resources = append(v.Resources, Link{ID: id, Name: resources[id].Title, Href: resourceHref(s, id)})
+	}
+	if len(s.SecurityDefinitions) > 0 {
+		v.SecuritySchemes = make(map[string]SecurityScheme)
+		for name, scheme := range s.SecurityDefinitions {
+			v.SecuritySchemes[name] = securitySchemeView(scheme)
+		}
+	}
+	return v
+}
+
+func specificationSummary(s *spec.APISpecification) Specification {
+	return Specification{
+		ID:          s.ID,
+		Title:       s.APIInfo.Title,
+		Description: s.APIInfo.Description,
+		Version:     s.Version,
+		Href:        specHref(s),
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func apiView(s *spec.APISpecification, api *spec.APIGroup) API {
+	v := API{
+		ID:       api.ID,
+		Name:     api.Name,
+		Version:  api.CurrentVersion,
+		Href:     apiHref(s, api),
+		Consumes: api.Consumes,
+		Produces: api.Produces,
+	}
+	for i := range api.Methods {
+		v.Methods = append(v.Methods, methodLink(s, api, &api.Methods[i]))
+	}
+	return v
+}
+
+func methodLink(s *spec.APISpecification, api *spec.APIGroup, m *spec.Method) Link {
+	return Link{ID: m.ID, Name: m.Name, Method: strings.ToUpper(m.Method), Path: m.Path, Href: methodHref(s, api, m)}
+}
+
+// -----------------------------------------------------------------------------
+
+func methodView(s *spec.APISpecification, api *spec.APIGroup, m *spec.Method) Method {
+	v := Method{
+		ID:            m.ID,
+		Name:          m.Name,
+		Description:   m.Description,
+		Method:        strings.ToUpper(m.Method),
+		Path:          m.Path,
+		OperationName: m.OperationName,
+		API:           api.ID,
+		Href:          methodHref(s, api, m),
+		Consumes:      m.Consumes,
+		Produces:      m.Produces,
+	}
+
+	for _, params := range [][]spec.Parameter{m.PathParams, m.QueryParams, m.HeaderParams, m.FormParams} {
+		for _, p := range params {
+			v.Parameters = append(v.Parameters, parameterView(s, &p))
+		}
+	}
+	if m.BodyParam != nil {
+		body := parameterView(s, m.BodyParam)
+		v.Body = &body
+	}
+
+	var codes []int
+	for code := range m.Responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		response := m.Responses[code]
+		v.Responses = append(v.Responses, responseView(s, code, &response))
+	}
+	if m.DefaultResponse != nil {
+		response := responseView(s, 0, m.DefaultResponse)
+		v.DefaultResponse = &response
+	}
+
+	if len(m.Security) > 0 {
+		v.Security = make(map[string][]string)
+		for name, security := range m.Security {
+			scopes := []string{}
+			for scope := range security.Scopes {
+				scopes = append(scopes, scope)
+			}
+			sort.Strings(scopes)
+			v.Security[name] = scopes
+		}
+	}
+
+	if len(m.CodeSamples) > 0 {
+		v.CodeSamples = make(map[string]string)
+		for _, sample := range m.CodeSamples {
+			v.CodeSamples[sample.Lang] = sample.Source
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, r := range m.Resources {
+		if r == nil || seen[r.ID] {
+			continue
+		}
+		seen[r.ID] = true
+		v.Resources = append(v.Resources, *resourceLink(s, r))
+	}
+	return v
+}
+
+func parameterView(s *spec.APISpecification, p *spec.Parameter) Parameter {
+	return Parameter{
+		Name:             p.Name,
+		In:               p.In,
+		Description:      p.Description,
+		Required:         p.Required,
+		Type:             p.Type,
+		CollectionFormat: p.CollectionFormat,
+		Enum:             p.Enum,
+		Resource:         resourceLink(s, p.Resource),
+		IsArray:          p.IsArray,
+	}
+}
+
+func responseView(s *spec.APISpecification, status int, r *spec.Response) Response {
+	v := Response{
+		Status:      status,
+		Description: r.Description,
+		Resource:    resourceLink(s, r.Resource),
+		IsArray:     r.IsArray,
+	}
+	for _, h := range r.Headers {
+		v.Headers = append(v.Headers, Header{
+			Name:        h.Name,
+			Description: h.Description,
+			Type:        h.Type,
+			Required:    h.Required,
+			Enum:        h.Enum,
+			Default:     h.Default,
+		})
+	}
+	return v
+}
+
+func resourceLink(s *spec.APISpecification, r *spec.Resource) *Link {
+	if r == nil {
+		return nil
+	}
+	return &Link{ID: r.ID, Name: r.Title, Href: resourceHref(s, r.ID)}
+}
+
+// -----------------------------------------------------------------------------
+
+func resourceView(s *spec.APISpecification, r *spec.Resource) *Resource {
+	v := propertyView(r, 0)
+	v.Href = resourceHref(s, r.ID)
+
+	if json.Valid([]byte(r.Example)) {
+		v.Example = json.RawMessage(r.Example)
+	}
+
+	var ids []string
+	for id := range r.Methods {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		m := r.Methods[id]
+		if m.APIGroup != nil {
+			v.Methods = append(v.Methods, methodLink(s, m.APIGroup, m))
+		}
+	}
+	return v
+}
+
+func propertyView(r *spec.Resource, depth int) *Resource {
+	v := &Resource{
+		ID:            r.ID,
+		Title:         r.Title,
+		Description:   r.Description,
+		Type:          r.Type,
+		Required:      r.Required,
+		ReadOnly:      r.ReadOnly,
+		Enum:          r.Enum,
+		Discriminator: r.Discriminator,
+	}
+	if depth > maxDepth {
+		return v
+	}
+	if len(r.Properties) > 0 {
+		v.Properties = make(map[string]*Resource)
+		for name, property := range r.Properties {
+			v.Properties[name] = propertyView(property, depth+1)
+		}
+	}
+	for _, variant := range r.Variants {
+		vv := Variant{Name: variant.Name, DiscriminatorValue: variant.DiscriminatorValue}
+		if variant.Resource != nil {
+			vv.Resource = propertyView(variant.Resource, depth+1)
+		}
+		v.Variants = append(v.Variants, vv)
+	}
+	return v
+}
+
+// -----------------------------------------------------------------------------
+
+func securitySchemeView(scheme spec.SecurityScheme) SecurityScheme {
+	return SecurityScheme{
+		Type:             scheme.Type,
+		Description:      scheme.Description,
+		ParamName:        scheme.ParamName,
+		ParamLocation:    scheme.ParamLocation,
+		Flow:             scheme.OAuth2Flow,
+		AuthorizationURL: scheme.AuthorizationUrl,
+		TokenURL:         scheme.TokenUrl,
+		Scopes:           scheme.Scopes,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Returns the resources of the current version of a specification, keyed by ID.
+func currentResources(s *spec.APISpecification) map[string]*spec.Resource {
+	r := make(map[string]*spec.Resource)
+	for _, list := range s.ResourceList {
+		for id, resource := range list {
+			if _, ok := r[id]; !ok {
+				r[id] = resource
+			}
+		}
+	}
+	return r
+}
+
+func resourceIDs(resources map[string]*spec.Resource) []string {
+	var ids []string
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,97 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package reference
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/changelog"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// ------------------------------------------------------------------------------------------------------------
+// Returns the two versions given by a "compare=<from>,<to>" request parameter, if
+// the request has one. The versions are empty if the parameter does not give two.
+func comparedVersions(req *http.Request) (from string, to string, ok bool) {
+	compare := req.FormValue("compare")
+	if compare == "" {
+		return "", "", false
+	}
+	versions := strings.Split(compare, ",")
+	if len(versions) != 2 {
+		return "", "", true
+	}
+	return strings.TrimSpace(versions[0]), strings.TrimSpace(versions[1]), true
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// Renders two versions of a method side by side.
+func (reg *registry) compareMethod(w http.ResponseWriter, req *http.Request, specification *spec.APISpecification, api spec.APIGroup, path string, from string, to string) {
+	if from == "" || to == "" {
+		reg.badCompare(w, req, specification)
+		return
+	}
+	f, fok := reg.pathVersionMethod[path][from]
+	t, tok := reg.pathVersionMethod[path][to]
+	if !fok || !tok {
+		reg.notFound(w, req, specification) // The method is not in both versions
+		return
+	}
+
+	logger.Tracef(req, "-- compare method %s versions %s and %s", path, from, to)
+
+	c := changelog.CompareMethod(&f, &t, from, to)
+
+	rnd := reg.rnd.For(specification)
+	rnd.HTML(w, http.StatusOK, "compare", rnd.DefaultVars(req, specification, render.Vars{"Title": t.Name, "API": api, "Method": t, "Comparison": c, "LatestVersion": api.CurrentVersion}))
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// Renders two versions of a resource side by side.
+func (reg *registry) compareResource(w http.ResponseWriter, req *http.Request, specification *spec.APISpecification, path string, from string, to string) {
+	if from == "" || to == "" {
+		reg.badCompare(w, req, specification)
+		return
+	}
+	f, fok := reg.pathVersionResource[path][from]
+	t, tok := reg.pathVersionResource[path][to]
+	if !fok || !tok {
+		reg.notFound(w, req, specification) // The resource is not in both versions
+		return
+	}
+
+	logger.Tracef(req, "-- compare resource %s versions %s and %s", path, from, to)
+
+	c := changelog.CompareResource(f, t, from, to)
+
+	rnd := reg.rnd.For(specification)
+	rnd.HTML(w, http.StatusOK, "compare", rnd.DefaultVars(req, specification, render.Vars{"Title": t.Title, "Resource": t, "Comparison": c, "LatestVersion": latestResourceVersion(specification, reg.pathVersionResource[path])}))
+}
+
+// ------------------------------------------------------------------------------------------------------------
+
+func (reg *registry) badCompare(w http.ResponseWriter, req *http.Request, specification *spec.APISpecification) {
+	rnd := reg.rnd.For(specification)
+	rnd.HTML(w, http.StatusBadRequest, "error", rnd.DefaultVars(req, specification, render.Vars{"error": "The compare parameter must give two versions, separated by a comma", "code": 400}))
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// end
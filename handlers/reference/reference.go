@@ -19,6 +19,9 @@ package reference
 
 import (
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
 	//"github.com/davecgh/go-spew/spew"
 	"github.com/dapperdox/dapperdox/logger"
@@ -30,18 +33,25 @@ import (
 type versionedMethod map[string]spec.Method      // key is version
 type versionedResource map[string]*spec.Resource // key is version
 
-var pathVersionMethod map[string]versionedMethod     // Key is path
-var pathVersionResource map[string]versionedResource // Key is path
+// registry holds the versions of the methods and resources documented
+type registry struct {
+	rnd                 *render.Renderer
+	pathVersionMethod   map[string]versionedMethod   // Key is path
+	pathVersionResource map[string]versionedResource // Key is path
+}
 
 // Register creates routes for specification resource
-func Register(r *pat.Router) {
+func Register(r *pat.Router, rnd *render.Renderer, suite map[string]*spec.APISpecification) {
 	logger.Infof(nil, "Registering reference documentation")
 
-	pathVersionMethod = make(map[string]versionedMethod)
-	pathVersionResource = make(map[string]versionedResource)
+	reg := &registry{
+		rnd:                 rnd,
+		pathVersionMethod:   make(map[string]versionedMethod),
+		pathVersionResource: make(map[string]versionedResource),
+	}
 
 	// Loop for all APISpecification's in the APISuite
-	for _, specification := range spec.APISuite {
+	for _, specification := range suite {
 
 		spec_id := "/" + specification.ID
 
@@ -49,7 +59,7 @@ func Register(r *pat.Router) {
 
 		for _, api := range specification.APIs {
 			logger.Debugf(nil, "  - Scanning API [%s] %s", api.ID, api.Name)
-			r.Path(spec_id + "/reference/" + api.ID).Methods("GET").HandlerFunc(APIHandler(specification, api))
+			r.Path(spec_id + "/reference/" + api.ID).Methods("GET").HandlerFunc(reg.APIHandler(specification, api))
 
 			version := api.CurrentVersion
 
@@ -60,22 +70,22 @@ func Register(r *pat.Router) {
 				logger.Debugf(nil, "    + method %s [%s]", path, method.Name)
 
 				// Add version->method to pathVersionMethod
-				if _, ok := pathVersionMethod[path]; !ok {
-					pathVersionMethod[path] = make(versionedMethod)
-					r.Path(path).Methods("GET").HandlerFunc(MethodHandler(specification, api, path))
+				if _, ok := reg.pathVersionMethod[path]; !ok {
+					reg.pathVersionMethod[path] = make(versionedMethod)
+					r.Path(path).Methods("GET").HandlerFunc(reg.MethodHandler(specification, api, path))
 				}
-				pathVersionMethod[path][version] = method
+				reg.pathVersionMethod[path][version] = method
 			}
 			for version, methods := range api.Versions {
 				for _, method := range methods {
 					logger.Debugf(nil, "    + %s %s", method.ID, method.Name)
 					path := spec_id + "/reference/" + api.ID + "/" + method.ID
 					// Add version->resource to pathVersionResource
-					if _, ok := pathVersionMethod[path]; !ok {
-						pathVersionMethod[path] = make(versionedMethod)
-						r.Path(path).Methods("GET").HandlerFunc(MethodHandler(specification, api, path))
+					if _, ok := reg.pathVersionMethod[path]; !ok {
+						reg.pathVersionMethod[path] = make(versionedMethod)
+						r.Path(path).Methods("GET").HandlerFunc(reg.MethodHandler(specification, api, path))
 					}
-					pathVersionMethod[path][version] = method
+					reg.pathVersionMethod[path][version] = method
 				}
 			}
 		}
@@ -86,11 +96,30 @@ func Register(r *pat.Router) {
 			for id, resource := range resources {
 				path := spec_id + "/resources/" + id
 				logger.Debugf(nil, "      + resource %s", id)
-				if _, ok := pathVersionResource[path]; !ok {
-					pathVersionResource[path] = make(versionedResource)
-					r.Path(path).Methods("GET").HandlerFunc(GlobalResourceHandler(specification, path))
+				if _, ok := reg.pathVersionResource[path]; !ok {
+					reg.pathVersionResource[path] = make(versionedResource)
+					r.Path(path).Methods("GET").HandlerFunc(reg.GlobalResourceHandler(specification, path))
+				}
+				reg.pathVersionResource[path][version] = resource
+			}
+		}
+
+		// Resources of the earlier revisions that are documented as versions
+		for _, revision := range specification.Revisions {
+			if _, ok := specification.APIVersions[revision.Version]; !ok {
+				continue
+			}
+			logger.Debugf(nil, "    - Version %s", revision.Version)
+			for _, resources := range revision.ResourceList {
+				for id, resource := range resources {
+					path := spec_id + "/resources/" + id
+					logger.Debugf(nil, "      + resource %s", id)
+					if _, ok := reg.pathVersionResource[path]; !ok {
+						reg.pathVersionResource[path] = make(versionedResource)
+						r.Path(path).Methods("GET").HandlerFunc(reg.GlobalResourceHandler(specification, path))
+					}
+					reg.pathVersionResource[path][revision.Version] = resource
 				}
-				pathVersionResource[path][version] = resource
 			}
 		}
 	}
@@ -123,7 +152,7 @@ func getMethodVersions(api spec.APIGroup, versions versionedMethod) []string {
 		keys[ix] = key
 		ix++
 	}
-	return keys
+	return sortVersions(keys)
 }
 
 // ------------------------------------------------------------------------------------------------------------
@@ -139,7 +168,7 @@ func getAPIVersions(api spec.APIGroup) []string {
 		keys[ix] = key
 		ix++
 	}
-	return keys
+	return sortVersions(keys)
 }
 
 // ------------------------------------------------------------------------------------------------------------
@@ -155,103 +184,183 @@ func getResourceVersions(api spec.APIGroup, versions versionedResource) []string
 		keys[ix] = key
 		ix++
 	}
-	return keys
+	return sortVersions(keys)
 }
 
 // ------------------------------------------------------------------------------------------------------------
 // APIHandler is a http.Handler for rendering API reference docs
-func APIHandler(specification *spec.APISpecification, api spec.APIGroup) func(w http.ResponseWriter, req *http.Request) {
+func (reg *registry) APIHandler(specification *spec.APISpecification, api spec.APIGroup) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 
 		version := req.FormValue("v") // Get the resource version
 		if version == "" {
 			version = api.CurrentVersion
 		}
+		if _, ok := api.Versions[version]; !ok && version != api.CurrentVersion {
+			reg.notFound(w, req, specification)
+			return
+		}
 		versions := getAPIVersions(api)
 		methods := getVersionMethod(api, version)
 
 		tmpl := "api"
 		customTmpl := "reference/" + api.ID
-		if render.TemplateLookup(customTmpl) != nil {
+		rnd := reg.rnd.For(specification)
+		if rnd.TemplateLookup(customTmpl) != nil {
 			tmpl = customTmpl
 		}
 
 		logger.Tracef(nil, "-- template: %s  Version %s", tmpl, version)
 
-		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": api.Name, "API": api, "Methods": methods, "Version": version, "Versions": versions, "LatestVersion": api.CurrentVersion}))
+		rnd.HTML(w, http.StatusOK, tmpl, rnd.DefaultVars(req, specification, render.Vars{"Title": api.Name, "API": api, "Methods": methods, "Version": version, "Versions": versions, "LatestVersion": api.CurrentVersion}))
 	}
 }
 
 // ------------------------------------------------------------------------------------------------------------
 // MethodHandler is a http.Handler for rendering API method reference docs
-func MethodHandler(specification *spec.APISpecification, api spec.APIGroup, path string) func(w http.ResponseWriter, req *http.Request) {
+func (reg *registry) MethodHandler(specification *spec.APISpecification, api spec.APIGroup, path string) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 
+		if from, to, ok := comparedVersions(req); ok {
+			reg.compareMethod(w, req, specification, api, path, from, to)
+			return
+		}
+
 		version := req.FormValue("v") // Get the resource version
 		if version == "" {
 			version = api.CurrentVersion
 		}
-		versions := getMethodVersions(api, pathVersionMethod[path])
-		method := pathVersionMethod[path][version]
+		method, ok := reg.pathVersionMethod[path][version]
+		if !ok {
+			reg.notFound(w, req, specification) // The method is not in this version of the API
+			return
+		}
+		versions := getMethodVersions(api, reg.pathVersionMethod[path])
 
 		tmpl := "method"
 		customTmpl := "reference/" + api.ID + "/" + method.ID
-		if render.TemplateLookup(customTmpl) != nil {
+		rnd := reg.rnd.For(specification)
+		if rnd.TemplateLookup(customTmpl) != nil {
 			tmpl = customTmpl
 		}
 
 		logger.Tracef(nil, "-- template: %s  Version %s", tmpl, version)
 
-		// TODO default to latest if version not found, or 404 ?
-		method = pathVersionMethod[path][version]
-
 		//logger.Debugf(nil, "Method versions:\n")
 		//spew.Dump(versions)
 
-		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": method.Name, "API": api, "Method": method, "Version": version, "Versions": versions, "LatestVersion": api.CurrentVersion}))
+		rnd.HTML(w, http.StatusOK, tmpl, rnd.DefaultVars(req, specification, render.Vars{"Title": method.Name, "API": api, "Method": method, "Version": version, "Versions": versions, "LatestVersion": api.CurrentVersion, "Comparable": true}))
 	}
 }
 
 // ------------------------------------------------------------------------------------------------------------
 // ResourceHandler is a http.Handler for rendering API resource reference docs
-func GlobalResourceHandler(specification *spec.APISpecification, path string) func(w http.ResponseWriter, req *http.Request) {
+func (reg *registry) GlobalResourceHandler(specification *spec.APISpecification, path string) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 
-		version := req.FormValue("v") // Get the resource version - blank is the latest
-		if version == "" {
-			version = "latest"
+		if from, to, ok := comparedVersions(req); ok {
+			reg.compareResource(w, req, specification, path, from, to)
+			return
 		}
 
+		versionList := reg.pathVersionResource[path]
+
 		// Get list of versions
 		var versions []string
 		ix := 0
-		versionList := pathVersionResource[path]
 
 		if len(versionList) > 1 {
-			// There is more than one version (there is always a "latest"), so
-			// compile list of those available for resource
-			versions = make([]string, len(pathVersionResource[path]))
+			// There is more than one version, so compile list of those available for resource
+			versions = make([]string, len(versionList))
 			for key := range versionList {
 				versions[ix] = key
 				ix++
 			}
+			versions = sortVersions(versions)
+		}
+
+		version := req.FormValue("v") // Get the resource version - blank is the latest
+		if version == "" {
+			version = latestResourceVersion(specification, versionList)
 		}
 
-		resource := pathVersionResource[path][version]
+		resource, ok := versionList[version]
+		if !ok {
+			reg.notFound(w, req, specification)
+			return
+		}
 
 		logger.Debugf(nil, "Render resource "+resource.ID)
 		tmpl := "resource"
 
 		customTmpl := "resources/" + resource.ID
 
-		if render.TemplateLookup(customTmpl) != nil {
+		rnd := reg.rnd.For(specification)
+		if rnd.TemplateLookup(customTmpl) != nil {
 			tmpl = customTmpl
 		}
 
 		logger.Tracef(nil, "-- template: %s  Version %s", tmpl, version)
 
-		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": resource.Title, "Resource": resource, "Version": version, "Versions": versions}))
+		rnd.HTML(w, http.StatusOK, tmpl, rnd.DefaultVars(req, specification, render.Vars{"Title": resource.Title, "Resource": resource, "Version": version, "Versions": versions, "LatestVersion": latestResourceVersion(specification, versionList), "Comparable": true}))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// Returns the version of a resource documented by default. This is the version of the
+// specification if the resource has one, else the latest version of the resource that
+// is not from an earlier revision.
+func latestResourceVersion(specification *spec.APISpecification, versions versionedResource) string {
+	if _, ok := versions[specification.Version]; ok {
+		return specification.Version
+	}
+	var current []string
+	for version := range versions {
+		if _, ok := specification.APIVersions[version]; !ok {
+			current = append(current, version)
+		}
+	}
+	if len(current) == 0 {
+		return ""
+	}
+	return sortVersions(current)[0]
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// Sorts versions latest first. Versions are compared a dot separated part at a time,
+// numerically where both parts are numbers, so that 1.10 follows 1.9.
+func sortVersions(versions []string) []string {
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) > 0
+	})
+	return versions
+}
+
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(strings.ToLower(a), "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(strings.ToLower(b), "v"), ".")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case (aerr != nil || berr != nil) && as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
 	}
+	return len(as) - len(bs)
+}
+
+// ------------------------------------------------------------------------------------------------------------
+
+func (reg *registry) notFound(w http.ResponseWriter, req *http.Request, specification *spec.APISpecification) {
+	rnd := reg.rnd.For(specification)
+	rnd.HTML(w, http.StatusNotFound, "error", rnd.DefaultVars(req, specification, render.Vars{"error": "Page not found", "code": 404}))
 }
 
 // ------------------------------------------------------------------------------------------------------------
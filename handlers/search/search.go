@@ -0,0 +1,84 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package search
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render"
+	"github.com/dapperdox/dapperdox/render/asset"
+	"github.com/dapperdox/dapperdox/search"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+const defaultLimit = 50
+
+// ----------------------------------------------------------------------------------------
+// Register builds the search index from the loaded specifications and the guides
+// compiled by rnd, and creates the search routes.
+func Register(r *pat.Router, rnd *render.Renderer, suite map[string]*spec.APISpecification) {
+	logger.Infof(nil, "Registering search")
+
+	index := search.Build(suite, func(specification *spec.APISpecification) *asset.Store {
+		return rnd.For(specification).Assets()
+	})
+
+	r.Path("/search").Methods("GET").HandlerFunc(searchHandler(rnd, index))
+	r.Path("/search.json").Methods("GET").HandlerFunc(searchJSONHandler(rnd, index))
+}
+
+// ----------------------------------------------------------------------------------------
+func searchHandler(rnd *render.Renderer, index *search.Index) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query, hits := find(index, req)
+
+		logger.Tracef(req, "Render HTML for search '%s', %d hits", query, len(hits))
+
+		rnd.HTML(w, http.StatusOK, "search", rnd.DefaultVars(req, nil, render.Vars{"Title": "Search", "Query": query, "Hits": hits}))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+func searchJSONHandler(rnd *render.Renderer, index *search.Index) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query, hits := find(index, req)
+
+		rnd.JSON(w, http.StatusOK, map[string]interface{}{"query": query, "hits": hits})
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// Runs the query given by the q parameter, limited to the number of hits given by the
+// limit parameter.
+func find(index *search.Index, req *http.Request) (string, []search.Hit) {
+	query := strings.TrimSpace(req.URL.Query().Get("q"))
+
+	limit := defaultLimit
+	if l, err := strconv.Atoi(req.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	return query, index.Search(query, limit)
+}
+
+// ----------------------------------------------------------------------------------------
+// end
@@ -0,0 +1,197 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package specs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Specifications are served as JSON or YAML, whichever they are written in, or
+// converted to the other. Documents are parsed as YAML, which JSON is a subset of,
+// so that the order of members is kept when converting.
+
+// Formats a specification can be served in
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+var contentTypes = map[string]string{
+	formatJSON: "application/json",
+	formatYAML: "application/yaml",
+}
+
+// -----------------------------------------------------------------------------
+// Returns the format of a document from the extension of its path.
+func formatOf(route string) string {
+	switch strings.ToLower(path.Ext(route)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	}
+	return formatJSON
+}
+
+// -----------------------------------------------------------------------------
+// Returns the format asked for by the Accept header of a request, or the empty
+// string if it does not ask for JSON or YAML.
+func acceptedFormat(req *http.Request) string {
+	for _, accept := range req.Header["Accept"] {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			parts := strings.Split(mediaRange, ";")
+			if refused(parts[1:]) {
+				continue
+			}
+			switch mediaType := strings.ToLower(strings.TrimSpace(parts[0])); {
+			case mediaType == "application/json", mediaType == "text/json", strings.HasSuffix(mediaType, "+json"):
+				return formatJSON
+			case strings.HasSuffix(mediaType, "/yaml"), strings.HasSuffix(mediaType, "/x-yaml"), strings.HasSuffix(mediaType, "+yaml"):
+				return formatYAML
+			}
+		}
+	}
+	return ""
+}
+
+// A media range with a quality of zero is not acceptable
+func refused(params []string) bool {
+	for _, param := range params {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) == 2 && strings.ToLower(kv[0]) == "q" && strings.Trim(strings.TrimSpace(kv[1]), "0.") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// Parses a JSON or YAML document.
+func parseDocument(document []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(document, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("document is empty")
+	}
+	return doc.Content[0], nil
+}
+
+// -----------------------------------------------------------------------------
+// Encodes a parsed document in the format given.
+func encodeDocument(node *yaml.Node, format string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if format == formatYAML {
+		blockStyle(node, make(map[*yaml.Node]bool))
+
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(node); err != nil {
+			return nil, err
+		}
+		enc.Close()
+		return buf.Bytes(), nil
+	}
+
+	if err := writeJSON(&buf, node); err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes(), nil
+}
+
+// Documents parsed from JSON are in the flow style, with quoted strings. These are
+// cleared so that the YAML is written in the block style, quoting only as needed.
+func blockStyle(node *yaml.Node, seen map[*yaml.Node]bool) {
+	if seen[node] {
+		return
+	}
+	seen[node] = true
+
+	node.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+	for _, child := range node.Content {
+		blockStyle(child, seen)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Writes a parsed document as JSON, keeping the order of object members.
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return writeJSON(buf, node.Alias)
+
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case yaml.ScalarNode:
+		var value interface{} = node.Value
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool", "!!null":
+			if err := node.Decode(&value); err != nil {
+				return err
+			}
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			b, _ = json.Marshal(node.Value) // Such as infinity, which JSON has no number for
+		}
+		buf.Write(b)
+
+	default:
+		return fmt.Errorf("line %d: unexpected YAML node", node.Line)
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,273 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package specs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/spec"
+	"gopkg.in/yaml.v3"
+)
+
+// A resolved specification is bundled into a single document, by replacing each
+// $ref with the value it refers to. References may be to the same document, to
+// other specification files served, or to remote documents, and are relative to the
+// document they are in. A reference that refers back to itself, as recursive models
+// do, cannot be replaced, so is left in place, relative to the bundled document if
+// it is a reference into it, else to the site.
+//
+// Each specification is resolved when first asked for, and the result is kept until
+// the site is rebuilt, so that remote documents are not fetched for every request.
+
+// fetchTimeout bounds how long a remote document that is referred to is waited for
+const fetchTimeout = 30 * time.Second
+
+var client = &http.Client{Timeout: fetchTimeout}
+
+// resolvedDocuments holds the resolved specifications served, in each format
+type resolvedDocuments struct {
+	sync.Mutex
+	documents   map[string][]byte      // Specification files served, keyed by route
+	resolutions map[string]*resolution // Keyed by route
+}
+
+type resolution struct {
+	once    sync.Once
+	encoded map[string][]byte // Keyed by format
+	err     error
+}
+
+type resolver struct {
+	root      string
+	documents map[string][]byte     // Specification files served, keyed by route
+	loaded    map[string]*yaml.Node // Documents parsed, keyed by location
+	active    map[string]bool       // References being resolved
+}
+
+// -----------------------------------------------------------------------------
+// Returns resolved documents for the specification files given, which must all be
+// added before any is resolved.
+func newResolvedDocuments(documents map[string][]byte) *resolvedDocuments {
+	return &resolvedDocuments{
+		documents:   documents,
+		resolutions: make(map[string]*resolution),
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Returns the specification served at route in format, with its references
+// resolved. It is resolved only the first time it is asked for, in any format.
+func (rd *resolvedDocuments) get(route string, format string) ([]byte, error) {
+	rd.Lock()
+	res, ok := rd.resolutions[route]
+	if !ok {
+		res = &resolution{}
+		rd.resolutions[route] = res
+	}
+	rd.Unlock()
+
+	res.once.Do(func() {
+		node, err := resolveDocument(route, rd.documents)
+		if err != nil {
+			res.err = err
+			return
+		}
+		// JSON is written first, as writing YAML changes the style of the nodes
+		res.encoded = make(map[string][]byte)
+		for _, f := range []string{formatJSON, formatYAML} {
+			if res.encoded[f], err = encodeDocument(node, f); err != nil {
+				res.err = err
+				return
+			}
+		}
+	})
+	return res.encoded[format], res.err
+}
+
+// -----------------------------------------------------------------------------
+// Returns the specification served at route, with its references resolved.
+func resolveDocument(route string, documents map[string][]byte) (*yaml.Node, error) {
+	r := &resolver{
+		root:      route,
+		documents: documents,
+		loaded:    make(map[string]*yaml.Node),
+		active:    make(map[string]bool),
+	}
+	node, err := r.load(route)
+	if err != nil {
+		return nil, err
+	}
+	return r.resolve(route, node)
+}
+
+// -----------------------------------------------------------------------------
+// Returns a copy of node, in the document at location, with its references resolved.
+func (r *resolver) resolve(location string, node *yaml.Node) (*yaml.Node, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return r.resolve(location, node.Alias)
+
+	case yaml.MappingNode:
+		if ref, ok := reference(node); ok {
+			return r.follow(location, ref)
+		}
+		fallthrough
+
+	case yaml.SequenceNode:
+		resolved := *node
+		resolved.Content = make([]*yaml.Node, len(node.Content))
+		for i, child := range node.Content {
+			c, err := r.resolve(location, child)
+			if err != nil {
+				return nil, err
+			}
+			resolved.Content[i] = c
+		}
+		return &resolved, nil
+	}
+	return node, nil
+}
+
+// Returns the reference made by a mapping, if it is a reference object.
+func reference(node *yaml.Node) (string, bool) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "$ref" && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value, true
+		}
+	}
+	return "", false
+}
+
+// -----------------------------------------------------------------------------
+// Returns the resolved value referred to by ref, from the document at location.
+func (r *resolver) follow(location string, ref string) (*yaml.Node, error) {
+	target, pointer, err := spec.ResolveReference(location, ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid reference %s: %s", location, ref, err)
+	}
+
+	key := target + "#" + pointer
+	if r.active[key] {
+		if target == r.root {
+			key = "#" + pointer
+		}
+		return refNode(key), nil
+	}
+	r.active[key] = true
+	defer delete(r.active, key)
+
+	document, err := r.load(target)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot resolve reference %s: %s", location, ref, err)
+	}
+	node, err := lookup(document, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot resolve reference %s: %s", location, ref, err)
+	}
+	return r.resolve(target, node)
+}
+
+func refNode(ref string) *yaml.Node {
+	return &yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "$ref"},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: ref},
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Loads the document at location, which is either the route of a specification file
+// served or the URL of a remote document.
+func (r *resolver) load(location string) (*yaml.Node, error) {
+	if node, ok := r.loaded[location]; ok {
+		return node, nil
+	}
+
+	document, ok := r.documents[location]
+	if !ok {
+		if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+			return nil, fmt.Errorf("%s not found", location)
+		}
+		var err error
+		if document, err = fetch(location); err != nil {
+			return nil, err
+		}
+	}
+
+	node, err := parseDocument(document)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", location, err)
+	}
+	r.loaded[location] = node
+	return node, nil
+}
+
+// Fetches a remote document.
+func fetch(location string) ([]byte, error) {
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", location, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// -----------------------------------------------------------------------------
+// Returns the node a JSON pointer refers to.
+func lookup(node *yaml.Node, pointer string) (*yaml.Node, error) {
+	for _, token := range spec.PointerTokens(pointer) {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		var next *yaml.Node
+
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == token {
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("#%s not found", pointer)
+		}
+		node = next
+	}
+	return node, nil
+}
+
+// -----------------------------------------------------------------------------
@@ -22,23 +22,19 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/dapperdox/dapperdox/config"
 	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
 	"github.com/gorilla/pat"
 )
 
-var specMap map[string][]byte
-var specReplacer *strings.Replacer
-
-// Register creates routes for each static resource
-func Register(r *pat.Router) {
-
-	cfg, err := config.Get()
-	if err != nil {
-		logger.Errorf(nil, "error configuring app: %s", err)
-	}
+// Register creates routes for each specification file in the spec-dir, with the URLs
+// in them rewritten by loader, or by the loader of the specification of suite the file
+// is of
+func Register(r *pat.Router, cfg *config.Config, loader *spec.Loader, suite map[string]*spec.APISpecification) {
 
 	logger.Infof(nil, "Registering specifications")
 
@@ -47,27 +43,6 @@ func Register(r *pat.Router) {
 		return
 	}
 
-	// Build a replacer to search/replace specification URLs
-	if specReplacer == nil {
-		var replacements []string
-
-		// Configure the replacer with key=value pairs
-		for i := range cfg.SpecRewriteURL {
-
-			slice := strings.Split(cfg.SpecRewriteURL[i], "=")
-
-			switch len(slice) {
-			case 1: // Map between configured URL and site URL
-				replacements = append(replacements, slice[0], cfg.SiteURL)
-			case 2: // Map between configured to=from URL pair
-				replacements = append(replacements, slice...)
-			default:
-				panic("Invalid DocumentWriteUrl - does not contain an = delimited from=to pair")
-			}
-		}
-		specReplacer = strings.NewReplacer(replacements...)
-	}
-
 	base, err := filepath.Abs(filepath.Clean(cfg.SpecDir))
 	if err != nil {
 		logger.Errorf(nil, "Error forming specification path: %s", err)
@@ -77,7 +52,17 @@ func Register(r *pat.Router) {
 
 	base = filepath.ToSlash(base)
 
-	specMap = make(map[string][]byte)
+	// Build a fresh map, so that a reload does not disturb routes that are still being served.
+	documents := make(map[string][]byte)
+	resolved := newResolvedDocuments(documents)
+
+	ids := make(map[string]string) // Specification IDs, keyed by location
+	for id, specification := range suite {
+		ids[specification.URL] = id
+		for _, revision := range specification.Revisions {
+			ids[revision.URL] = id
+		}
+	}
 
 	err = filepath.Walk(base, func(path string, _ os.FileInfo, _ error) error {
 
@@ -92,32 +77,93 @@ func Register(r *pat.Router) {
 		ext := filepath.Ext(path)
 
 		switch ext {
-		case ".json", ".yaml":
+		case ".json", ".yaml", ".yml":
 			// Strip base path and file extension
 			route := strings.TrimPrefix(path, base)
 
 			logger.Debugf(nil, "    = URL : %s", route)
 			logger.Tracef(nil, "    + File: %s", path)
 
-			specMap[route], _ = ioutil.ReadFile(path)
+			documents[route], _ = ioutil.ReadFile(path)
 
 			// Replace URLs in document
-			specMap[route] = []byte(specReplacer.Replace(string(specMap[route])))
+			documents[route] = loader.ForSpec(ids[route], route).RewriteURLs(documents[route])
 
-			r.Path(route).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-				serveSpec(w, route)
-			})
+			r.Path(route).Methods("GET").HandlerFunc(specHandler(route, documents, resolved, ""))
 		}
 		return nil
 	})
 	_ = err
+
+	// Each specification is also served in the other format, unless there is a file
+	// of that name.
+	for route := range documents {
+		stem := strings.TrimSuffix(route, filepath.Ext(route))
+		for format := range contentTypes {
+			alternate := stem + "." + format
+			if _, ok := documents[alternate]; ok || format == formatOf(route) {
+				continue
+			}
+			logger.Debugf(nil, "    = URL : %s", alternate)
+			r.Path(alternate).Methods("GET").HandlerFunc(specHandler(route, documents, resolved, format))
+		}
+	}
 }
 
-func serveSpec(w http.ResponseWriter, resource string) {
-	logger.Tracef(nil, "Serve file "+resource)
-	w.Header().Set("Content-Type", "application/json")
+// -----------------------------------------------------------------------------
+// Returns a handler serving the specification at route. If no format is given, the
+// request's Accept header chooses, else the specification is served as written. With
+// the parameter resolved=true, references are resolved into a single document.
+func specHandler(route string, documents map[string][]byte, resolved *resolvedDocuments, format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		serveFormat := format
+		if serveFormat == "" {
+			w.Header().Set("Vary", "Accept")
+			if serveFormat = acceptedFormat(req); serveFormat == "" {
+				serveFormat = formatOf(route)
+			}
+		}
+		resolve, _ := strconv.ParseBool(req.URL.Query().Get("resolved"))
+
+		document := documents[route]
+
+		if resolve || serveFormat != formatOf(route) {
+			var b []byte
+			var err error
+			if resolve {
+				b, err = resolved.get(route, serveFormat)
+			} else {
+				b, err = convertSpec(document, serveFormat)
+			}
+			if err != nil {
+				logger.Errorf(req, "Error serving specification %s: %s", route, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			document = b
+		}
+
+		serveSpec(w, req, route, document, serveFormat)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func convertSpec(document []byte, format string) ([]byte, error) {
+	node, err := parseDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	return encodeDocument(node, format)
+}
+
+// -----------------------------------------------------------------------------
+
+func serveSpec(w http.ResponseWriter, req *http.Request, resource string, document []byte, format string) {
+	logger.Tracef(req, "Serve file %s as %s", resource, format)
+	w.Header().Set("Content-Type", contentTypes[format])
 	w.Header().Set("Cache-control", "public, max-age=259200")
 	w.WriteHeader(200)
-	w.Write(specMap[resource])
+	w.Write(document)
 	return
 }
@@ -27,22 +27,35 @@ import (
 	"github.com/dapperdox/dapperdox/logger"
 	"github.com/dapperdox/dapperdox/render"
 	"github.com/dapperdox/dapperdox/render/asset"
+	"github.com/dapperdox/dapperdox/spec"
 	"github.com/gorilla/pat"
 )
 
 // Register creates routes for each static resource
-func Register(r *pat.Router) {
+func Register(r *pat.Router, rnd *render.Renderer, suite map[string]*spec.APISpecification) {
 	logger.Debugln(nil, "registering not found handler in static package")
 
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		render.HTML(w, http.StatusNotFound, "error", render.DefaultVars(req, nil, map[string]interface{}{"error": "Page not found", "code": 404}))
+		rnd.HTML(w, http.StatusNotFound, "error", rnd.DefaultVars(req, nil, map[string]interface{}{"error": "Page not found", "code": 404}))
 	})
 
 	logger.Debugln(nil, "registering static content handlers for static package")
 
+	registerAssets(r, rnd.Assets())
+
+	// Specifications rendered from assets of their own serve their static assets too
+	for _, specification := range suite {
+		if sr := rnd.For(specification); sr != rnd {
+			registerAssets(r, sr.Assets())
+		}
+	}
+}
+
+// Register routes for each static resource in assets
+func registerAssets(r *pat.Router, assets *asset.Store) {
 	var allow bool
 
-	for _, file := range asset.AssetNames() {
+	for _, file := range assets.AssetNames() {
 		mimeType := mime.TypeByExtension(filepath.Ext(file))
 
 		if mimeType == "" {
@@ -64,10 +77,10 @@ func Register(r *pat.Router) {
 			// Drop assets/static prefix
 			path := strings.TrimPrefix(file, "assets/static")
 
-			logger.Debugf(nil, "registering handler for static asset: %s", path)
+			logger.Debugf(nil, "registering handler for static asset: %s", assets.StaticPrefix()+path)
 
-			r.Path(path).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-				if b, err := asset.Asset("assets/static" + path); err == nil {
+			r.Path(assets.StaticPrefix() + path).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if b, err := assets.Asset("assets/static" + path); err == nil {
 					w.Header().Set("Content-Type", mimeType)
 					w.Header().Set("Cache-control", "public, max-age=259200")
 					w.WriteHeader(200)
@@ -0,0 +1,215 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package lint
+
+// This package reports the diagnostics found while loading specifications, for the
+// lint subcommand. Reports are written as plain text, JSON or JUnit XML, the latter
+// two being intended for continuous integration systems.
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// Formats supported by Write
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// Report holds the diagnostics of every specification that was linted
+type Report struct {
+	Specifications []SpecificationReport `json:"specifications"`
+	Errors         int                   `json:"errors"`
+	Warnings       int                   `json:"warnings"`
+}
+
+// SpecificationReport holds the diagnostics of a single specification
+type SpecificationReport struct {
+	Location    string           `json:"location"`
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Loaded      bool             `json:"loaded"`
+	Errors      int              `json:"errors"`
+	Warnings    int              `json:"warnings"`
+	Diagnostics spec.Diagnostics `json:"diagnostics"`
+}
+
+// ---------------------------------------------------------------------------
+// NewReport builds a report from the specifications that loaded, and those that
+// failed to load, as returned by spec.LoadSuite.
+func NewReport(suite map[string]*spec.APISpecification, failures map[string]*spec.APISpecification) *Report {
+	r := &Report{Specifications: []SpecificationReport{}}
+
+	r.add(suite, true)
+	r.add(failures, false)
+
+	sort.Sort(byLocation(r.Specifications))
+
+	return r
+}
+
+func (r *Report) add(specifications map[string]*spec.APISpecification, loaded bool) {
+	for _, s := range specifications {
+		diagnostics := s.Diagnostics
+		if diagnostics == nil {
+			diagnostics = spec.Diagnostics{}
+		}
+		sr := SpecificationReport{
+			Location:    s.URL,
+			ID:          s.ID,
+			Title:       s.APIInfo.Title,
+			Loaded:      loaded,
+			Errors:      diagnostics.Errors(),
+			Warnings:    diagnostics.Warnings(),
+			Diagnostics: diagnostics,
+		}
+		r.Errors += sr.Errors
+		r.Warnings += sr.Warnings
+		r.Specifications = append(r.Specifications, sr)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Passed returns true if no problems, errors or warnings, were found.
+func (r *Report) Passed() bool {
+	return r.Errors == 0 && r.Warnings == 0
+}
+
+// ---------------------------------------------------------------------------
+// Write writes the report to w in the given format.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText, "":
+		return r.writeText(w)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatJUnit:
+		return r.writeJUnit(w)
+	}
+	return fmt.Errorf("unknown lint format '%s', expected one of %s, %s or %s", format, FormatText, FormatJSON, FormatJUnit)
+}
+
+// ---------------------------------------------------------------------------
+
+func (r *Report) writeText(w io.Writer) error {
+	for _, s := range r.Specifications {
+		for _, d := range s.Diagnostics {
+			if _, err := fmt.Fprintln(w, d); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d specifications, %d errors, %d warnings\n", len(r.Specifications), r.Errors, r.Warnings)
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// JUnit XML. Each specification is a test suite, and each diagnostic a failed test
+// case. A specification without diagnostics has a single passing test case, so that
+// it is still counted.
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (r *Report) writeJUnit(w io.Writer) error {
+	suites := junitTestSuites{Name: "dapperdox lint"}
+
+	for _, s := range r.Specifications {
+		suite := junitTestSuite{Name: s.Location}
+
+		for _, d := range s.Diagnostics {
+			pointer := d.Pointer
+			if pointer == "" {
+				pointer = "/"
+			}
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      pointer,
+				ClassName: s.Location,
+				Failure: &junitFailure{
+					Message: d.Message,
+					Type:    string(d.Severity),
+					Text:    d.String(),
+				},
+			})
+		}
+		suite.Failures = len(suite.TestCases)
+
+		if len(suite.TestCases) == 0 {
+			suite.TestCases = append(suite.TestCases, junitTestCase{Name: "/", ClassName: s.Location})
+		}
+		suite.Tests = len(suite.TestCases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ---------------------------------------------------------------------------
+
+type byLocation []SpecificationReport
+
+func (s byLocation) Len() int           { return len(s) }
+func (s byLocation) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLocation) Less(i, j int) bool { return s[i].Location < s[j].Location }
+
+// ---------------------------------------------------------------------------
@@ -62,20 +62,36 @@ func (r *responseCapture) WriteHeader(status int) {
 
 // Handler wraps a http.Handler and logs the status code and total response time
 func Handler(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		rc := &responseCapture{w, 0}
+	return Observe(nil)(h)
+}
+
+// Observe returns a handler like Handler, that also passes the status code and total
+// response time of each request to observe, if not nil.
+func Observe(observe func(req *http.Request, status int, d time.Duration)) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rc := &responseCapture{w, 0}
 
-		s := time.Now()
-		Tracef(req, "request started: %v", s)
+			s := time.Now()
+			Tracef(req, "request started: %v", s)
 
-		h.ServeHTTP(rc, req)
+			h.ServeHTTP(rc, req)
 
-		e := time.Now()
-		Tracef(req, "request completed: %v", e)
+			e := time.Now()
+			Tracef(req, "request completed: %v", e)
+
+			if rc.statusCode == 0 {
+				rc.statusCode = http.StatusOK // Written without a header
+			}
 
-		d := e.Sub(s)
-		Infof(req, "%s %s (%d, %v)", req.Method, req.URL.Path, rc.statusCode, d)
-	})
+			d := e.Sub(s)
+			Infof(req, "%s %s (%d, %v)", req.Method, req.URL.Path, rc.statusCode, d)
+
+			if observe != nil {
+				observe(req, rc.statusCode, d)
+			}
+		})
+	}
 }
 
 // LevelString is a level to string lookup map
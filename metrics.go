@@ -0,0 +1,95 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package dapperdox
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+)
+
+// ---------------------------------------------------------------------------
+// Records a request served in the metrics, counted by the family of its route.
+func (s *Server) observe(req *http.Request, status int, d time.Duration) {
+	s.metrics.ObserveRequest(s.routeFamily(req.URL.Path), status, d)
+}
+
+// ---------------------------------------------------------------------------
+// Returns the family of the route serving a path, which is one of proxy, mock, api,
+// search, guides, reference, resources, changelog, home, static or other. Routes are
+// told apart by their path alone, so that requests that are not found are counted
+// against the family they were looking for.
+func (s *Server) routeFamily(p string) string {
+	for _, prefix := range s.proxyPaths {
+		if strings.HasPrefix(p, prefix) {
+			return "proxy"
+		}
+	}
+	if mock := strings.Trim(s.cfg.MockPrefix, "/"); mock != "" && strings.HasPrefix(p, "/"+mock+"/") {
+		return "mock"
+	}
+
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+
+	switch {
+	case segments[0] == "_api":
+		return "api"
+	case segments[0] == "search" || segments[0] == "search.json":
+		return "search"
+	case segments[0] == "guides":
+		return "guides"
+	case len(segments) == 1 && path.Ext(segments[0]) == "":
+		return "home" // The specification list, or a specification
+	}
+
+	if len(segments) > 1 {
+		switch segments[1] {
+		case "guides", "reference", "resources":
+			return segments[1]
+		case "changelog", "changelog.json":
+			return "changelog"
+		}
+	}
+	if path.Ext(p) != "" {
+		return "static" // Assets and the specifications themselves
+	}
+	return "other"
+}
+
+// ---------------------------------------------------------------------------
+// Returns the paths proxied, for every specification or for one alone.
+func proxyPaths(cfg *config.Config) []string {
+	var paths []string
+	for _, p := range cfg.Proxies() {
+		paths = append(paths, p.Path)
+	}
+	for _, key := range cfg.SpecConfigs() {
+		if sc, _ := cfg.SpecConfig(key, ""); sc.ProxyPath == nil {
+			continue
+		}
+		for _, p := range cfg.ForSpec(key, "").Proxies() {
+			paths = append(paths, p.Path)
+		}
+	}
+	return paths
+}
+
+// ---------------------------------------------------------------------------
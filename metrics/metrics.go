@@ -0,0 +1,240 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package metrics
+
+// This package counts the requests served by DapperDox, the requests it proxies and
+// the loads of its specifications, and writes them in the Prometheus text exposition
+// format:
+//
+//   dapperdox_http_requests_total                 Requests, by route family and status code
+//   dapperdox_http_request_duration_seconds       Latency of requests, by route family
+//   dapperdox_proxy_request_duration_seconds      Latency of proxied requests, by proxied path
+//   dapperdox_proxy_upstream_responses_total      Upstream responses, by proxied path and status code
+//   dapperdox_proxy_upstream_errors_total         Proxied requests the upstream did not respond to
+//   dapperdox_spec_loads_total                    Loads of the specifications, at start or on reload
+//   dapperdox_spec_load_failures_total            Specifications that failed to load
+//   dapperdox_specifications                      Specifications loaded, documented or failed
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Latency buckets, in seconds
+var buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics are the metrics of a server
+type Metrics struct {
+	requests       *family
+	durations      *family
+	proxyDurations *family
+	upstream       *family
+	upstreamErrors *family
+	loads          *family
+	loadFailures   *family
+	specifications *family
+}
+
+// ---------------------------------------------------------------------------
+// New returns a set of metrics, all zero.
+func New() *Metrics {
+	return &Metrics{
+		requests:       newFamily("dapperdox_http_requests_total", "Requests served, by route family and status code.", "counter", nil, "family", "code"),
+		durations:      newFamily("dapperdox_http_request_duration_seconds", "Latency of requests served, by route family.", "histogram", buckets, "family"),
+		proxyDurations: newFamily("dapperdox_proxy_request_duration_seconds", "Latency of proxied requests, by proxied path.", "histogram", buckets, "path"),
+		upstream:       newFamily("dapperdox_proxy_upstream_responses_total", "Responses to proxied requests, by proxied path and upstream status code.", "counter", nil, "path", "code"),
+		upstreamErrors: newFamily("dapperdox_proxy_upstream_errors_total", "Proxied requests that the upstream did not respond to, by proxied path.", "counter", nil, "path"),
+		loads:          newFamily("dapperdox_spec_loads_total", "Loads of the specifications, at start or on reload.", "counter", nil, "trigger"),
+		loadFailures:   newFamily("dapperdox_spec_load_failures_total", "Specifications that failed to load, at start or on reload.", "counter", nil, "trigger"),
+		specifications: newFamily("dapperdox_specifications", "Specifications of the current load, documented or failed.", "gauge", nil, "status"),
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ObserveRequest records a request served, to a route of the family given.
+func (m *Metrics) ObserveRequest(family string, status int, d time.Duration) {
+	m.requests.add(1, family, strconv.Itoa(status))
+	m.durations.observe(d.Seconds(), family)
+}
+
+// ---------------------------------------------------------------------------
+// ObserveProxy records the latency of a request proxied through path.
+func (m *Metrics) ObserveProxy(path string, d time.Duration) {
+	m.proxyDurations.observe(d.Seconds(), path)
+}
+
+// ObserveUpstream records the status of a response to a request proxied through path.
+func (m *Metrics) ObserveUpstream(path string, status int) {
+	m.upstream.add(1, path, strconv.Itoa(status))
+}
+
+// ObserveUpstreamError records a request proxied through path that failed.
+func (m *Metrics) ObserveUpstreamError(path string) {
+	m.upstreamErrors.add(1, path)
+}
+
+// ---------------------------------------------------------------------------
+// ObserveLoad records a load of the specifications, either at start or on reload,
+// with the number that were documented and that failed.
+func (m *Metrics) ObserveLoad(reload bool, documented int, failed int) {
+	trigger := "start"
+	if reload {
+		trigger = "reload"
+	}
+	m.loads.add(1, trigger)
+	m.loadFailures.add(float64(failed), trigger)
+	m.specifications.set(float64(documented), "documented")
+	m.specifications.set(float64(failed), "failed")
+}
+
+// ---------------------------------------------------------------------------
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var b bytes.Buffer
+	for _, f := range []*family{m.requests, m.durations, m.proxyDurations, m.upstream, m.upstreamErrors, m.loads, m.loadFailures, m.specifications} {
+		f.write(&b)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// ---------------------------------------------------------------------------
+// A family of metrics of the same name, one for each set of label values
+
+type family struct {
+	name    string
+	help    string
+	kind    string
+	buckets []float64
+	labels  []string
+
+	mu     sync.Mutex
+	series map[string]*series // Keyed by the label values
+}
+
+type series struct {
+	labels string // As written, name="value",...
+	value  float64
+	count  uint64   // Observations, of a histogram
+	counts []uint64 // Observations in each bucket, of a histogram
+}
+
+func newFamily(name string, help string, kind string, buckets []float64, labels ...string) *family {
+	return &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		buckets: buckets,
+		labels:  labels,
+		series:  make(map[string]*series),
+	}
+}
+
+// Returns the series of the label values, creating it if need be. The family must be
+// locked.
+func (f *family) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	if s, ok := f.series[key]; ok {
+		return s
+	}
+
+	var pairs []string
+	for i, label := range f.labels {
+		pairs = append(pairs, label+`="`+escape(values[i])+`"`)
+	}
+	s := &series{labels: strings.Join(pairs, ",")}
+	if f.buckets != nil {
+		s.counts = make([]uint64, len(f.buckets))
+	}
+	f.series[key] = s
+	return s
+}
+
+func (f *family) add(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.get(values).value += v
+}
+
+func (f *family) set(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.get(values).value = v
+}
+
+// Records an observation of a histogram. The value of the series is the sum of the
+// observations, and buckets count those no greater than their bound.
+func (f *family) observe(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.get(values)
+	s.value += v
+	s.count++
+	for i, le := range f.buckets {
+		if v <= le {
+			s.counts[i]++
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func (f *family) write(b *bytes.Buffer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+
+	var keys []string
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			fmt.Fprintf(b, "%s{%s} %s\n", f.name, s.labels, formatValue(s.value))
+			continue
+		}
+		for i, le := range f.buckets {
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", f.name, s.labels, formatValue(le), s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", f.name, s.labels, s.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", f.name, s.labels, formatValue(s.value))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", f.name, s.labels, s.count)
+	}
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// ---------------------------------------------------------------------------
@@ -0,0 +1,218 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package mock
+
+// This package serves mock responses for every method of the loaded specifications,
+// under the path prefix given by the mock-prefix option. A mock response is the
+// example of the chosen response, declared or generated, with its documented headers.
+//
+// The response chosen is the first success (2xx) response, unless the request asks
+// for another with a "Prefer: code=<status>" header.
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// -----------------------------------------------------------------------------
+// Prefix returns the path prefix the mock responses are served under, without a
+// trailing slash, or "" if mocking is disabled.
+func Prefix(cfg *config.Config) string {
+	prefix := strings.TrimSuffix(cfg.MockPrefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// -----------------------------------------------------------------------------
+// Register registers a mock handler for each method of every loaded specification.
+// Nothing is registered unless a mock prefix is configured.
+func Register(r *pat.Router, cfg *config.Config, suite map[string]*spec.APISpecification) {
+	prefix := Prefix(cfg)
+	if prefix == "" {
+		return
+	}
+
+	logger.Tracef(nil, "Registering mock paths under %s:\n", prefix)
+
+	registered := make(map[string]bool)
+	preflight := make(map[string][]string)
+
+	for _, specification := range suite {
+		for _, api := range specification.APIs {
+			for i := range api.Methods {
+				method := &api.Methods[i]
+				path := prefix + method.Path
+				verb := strings.ToUpper(method.Method)
+
+				key := verb + " " + path
+				if registered[key] {
+					logger.Warnf(nil, "Mock for %s is already registered by another specification", key)
+					continue
+				}
+				registered[key] = true
+				preflight[path] = append(preflight[path], verb)
+
+				logger.Tracef(nil, "+ %s\n", key)
+				r.Path(path).Methods(verb).HandlerFunc(handler(method))
+			}
+		}
+	}
+
+	// Browser applications served from another origin send a preflight request first
+	for path, verbs := range preflight {
+		if registered["OPTIONS "+path] {
+			continue
+		}
+		r.Path(path).Methods("OPTIONS").HandlerFunc(preflightHandler(verbs))
+	}
+
+	logger.Tracef(nil, "Registering mock paths done.\n")
+}
+
+// -----------------------------------------------------------------------------
+
+func handler(method *spec.Method) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", "*")
+
+		preferred := preferredStatus(req)
+		status, response := selectResponse(method, preferred)
+		if status == 0 {
+			logger.Infof(req, "MOCK %s %s (response %d not declared)", req.Method, req.URL.Path, preferred)
+			http.Error(w, fmt.Sprintf("Response %d is not declared for %s %s", preferred, strings.ToUpper(method.Method), method.Path), http.StatusBadRequest)
+			return
+		}
+
+		var body string
+		if response != nil {
+			for _, header := range response.Headers {
+				h.Set(header.Name, header.Example())
+			}
+			body = response.Example()
+		}
+		if body != "" && status != http.StatusNoContent {
+			h.Set("Content-Type", contentType(method.Produces))
+		} else {
+			body = ""
+		}
+
+		logger.Infof(req, "MOCK %s %s (%d)", req.Method, req.URL.Path, status)
+
+		w.WriteHeader(status)
+		if req.Method != "HEAD" {
+			w.Write([]byte(body))
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func preflightHandler(verbs []string) http.HandlerFunc {
+	allow := strings.Join(append(verbs, "OPTIONS"), ", ")
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		h := w.Header()
+		h.Set("Allow", allow)
+		h.Set("Access-Control-Allow-Origin", "*")
+		h.Set("Access-Control-Allow-Methods", allow)
+		if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Returns the status code asked for by a "Prefer: code=<status>" request header,
+// or zero if none was.
+func preferredStatus(req *http.Request) int {
+	for _, prefer := range req.Header["Prefer"] {
+		for _, preference := range strings.Split(prefer, ",") {
+			parts := strings.SplitN(strings.TrimSpace(preference), "=", 2)
+			if len(parts) != 2 || strings.ToLower(strings.TrimSpace(parts[0])) != "code" {
+				continue
+			}
+			if code, err := strconv.Atoi(strings.Trim(strings.TrimSpace(parts[1]), `"`)); err == nil {
+				return code
+			}
+		}
+	}
+	return 0
+}
+
+// -----------------------------------------------------------------------------
+// Returns the status code and response to mock. With no preferred status, this is
+// the first success response, else the first declared, else the default response
+// as a 200. A preferred status must be declared, unless it is a valid HTTP status
+// and there is a default response to stand in for it. A zero status is returned if
+// no response can be mocked.
+func selectResponse(method *spec.Method, preferred int) (int, *spec.Response) {
+
+	if preferred != 0 {
+		if response, ok := method.Responses[preferred]; ok {
+			return preferred, &response
+		}
+		if method.DefaultResponse != nil && preferred >= 100 && preferred <= 599 {
+			return preferred, method.DefaultResponse
+		}
+		return 0, nil
+	}
+
+	var codes []int
+	for code := range method.Responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		if code >= 200 && code < 300 {
+			response := method.Responses[code]
+			return code, &response
+		}
+	}
+	if len(codes) > 0 {
+		response := method.Responses[codes[0]]
+		return codes[0], &response
+	}
+	return http.StatusOK, method.DefaultResponse
+}
+
+// -----------------------------------------------------------------------------
+// Examples are JSON, so a JSON media type is chosen from those the method produces.
+func contentType(produces []string) string {
+	for _, mediaType := range produces {
+		if strings.Contains(strings.ToLower(mediaType), "json") {
+			return mediaType
+		}
+	}
+	return "application/json"
+}
+
+// -----------------------------------------------------------------------------
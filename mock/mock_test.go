@@ -0,0 +1,60 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+func TestPreferredStatus(t *testing.T) {
+	method := &spec.Method{
+		Method:          "post",
+		Path:            "/v2/user",
+		Responses:       map[int]spec.Response{201: {}},
+		DefaultResponse: &spec.Response{},
+	}
+
+	tests := []struct {
+		prefer string
+		status int
+	}{
+		{"", http.StatusCreated},
+		{"code=201", http.StatusCreated},
+		{"code=404", http.StatusNotFound},
+		{"code=42", http.StatusBadRequest},
+		{"code=600", http.StatusBadRequest},
+		{"code=-1", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/mock/v2/user", nil)
+		if test.prefer != "" {
+			req.Header.Set("Prefer", test.prefer)
+		}
+		w := httptest.NewRecorder()
+		handler(method)(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("Prefer %q: got status %d, want %d", test.prefer, w.Code, test.status)
+		}
+	}
+}
@@ -25,9 +25,9 @@ import (
 	"net"
 )
 
-func GetListener(tlsEnabled *bool) (net.Listener, error) {
-
-	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+// GetListener listens on the configured bind address, using TLS if a certificate and
+// key are configured.
+func GetListener(cfg *config.Config) (net.Listener, error) {
 
 	useTLS := 0
 	if len(cfg.TLSCertificate) > 0 {
@@ -68,6 +68,5 @@ func GetListener(tlsEnabled *bool) (net.Listener, error) {
 	}
 
 	logger.Infof(nil, "listening on %s for SECURED connections", cfg.BindAddr)
-	*tlsEnabled = true
 	return tls.Listen("tcp", cfg.BindAddr, tlscfg)
 }
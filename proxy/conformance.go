@@ -0,0 +1,201 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// The responses to proxied requests for documented methods are checked against the
+// documented responses. The differences found are given in a response header, so
+// that they can be seen alongside the response in the explorer, and are logged. A
+// summary of the conformance of each method is kept, to find the specifications that
+// have drifted from the services they document.
+
+const conformanceHeader = "X-DapperDox-Conformance"
+
+// maxCheckedBody is the largest response body checked. The status and headers of a
+// response with a larger body are still checked.
+const maxCheckedBody = 10 << 20
+
+// Context key of the documented method a proxied request calls
+type contextKey int
+
+const methodKey contextKey = 0
+
+// documentedMethod is the documented method a proxied request calls, with the ID of
+// the specification documenting it
+type documentedMethod struct {
+	specification string
+	method        *spec.Method
+}
+
+// methodConformance summarises how the responses of a documented method conform
+type methodConformance struct {
+	Specification  string           `json:"specification"`
+	Method         string           `json:"method"`
+	Operation      string           `json:"operation,omitempty"`
+	Responses      int              `json:"responses"`
+	NonConforming  int              `json:"nonConforming"`
+	LastStatus     int              `json:"lastStatus,omitempty"` // Of the last response that did not conform
+	LastViolations []spec.Violation `json:"lastViolations,omitempty"`
+	LastSeen       *time.Time       `json:"lastSeen,omitempty"`
+}
+
+// Conformance summarises how the responses of each method called through the proxy
+// conform. It is kept across reloads of the specifications.
+type Conformance struct {
+	sync.Mutex
+	methods map[string]*methodConformance
+}
+
+// -----------------------------------------------------------------------------
+// NewConformance returns an empty conformance summary.
+func NewConformance() *Conformance {
+	return &Conformance{methods: make(map[string]*methodConformance)}
+}
+
+// -----------------------------------------------------------------------------
+// Checks the response to a proxied request, if the request calls a documented
+// method. Used as the ModifyResponse function of the reverse proxy.
+func (conformance *Conformance) check(resp *http.Response) error {
+	documented, ok := resp.Request.Context().Value(methodKey).(documentedMethod)
+	if !ok {
+		return nil
+	}
+	method := documented.method
+
+	// Only a JSON body is checked, so only a JSON body is buffered. An encoded body
+	// cannot be checked, though the status and headers still can.
+	var body []byte
+	if encoding := resp.Header.Get("Content-Encoding"); (encoding == "" || encoding == "identity") && isJSON(resp.Header.Get("Content-Type")) {
+		b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCheckedBody+1))
+		if err != nil {
+			resp.Body.Close()
+			return err
+		}
+		// Put what was read back in front of the rest of the body, to be returned
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+
+		if len(b) <= maxCheckedBody {
+			body = b
+		} else {
+			logger.Debugf(resp.Request, "Response to %s is larger than %d bytes, so its body is not checked", methodName(method), maxCheckedBody)
+		}
+	}
+
+	violations := method.ValidateResponse(resp.StatusCode, resp.Header, body)
+	conformance.record(documented.specification, method, resp.StatusCode, violations)
+
+	if len(violations) == 0 {
+		resp.Header.Set(conformanceHeader, "conforms")
+		return nil
+	}
+
+	summary := make([]string, len(violations))
+	for i, v := range violations {
+		summary[i] = v.String()
+		logger.Warnf(resp.Request, "Response to %s (%d) does not conform: %s", methodName(method), resp.StatusCode, v)
+	}
+	resp.Header.Set(conformanceHeader, strings.Join(summary, "; "))
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Returns whether a response of the content type given is checked as JSON. A
+// response without a content type is assumed to be JSON.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.Contains(strings.ToLower(t), "json")
+}
+
+// -----------------------------------------------------------------------------
+// Records a response to a documented method. Methods are keyed by specification as well as by path, as specifications proxied
+// to different targets may document the same path.
+func (conformance *Conformance) record(specification string, method *spec.Method, status int, violations []spec.Violation) {
+	name := methodName(method)
+	key := specification + " " + name
+
+	conformance.Lock()
+	defer conformance.Unlock()
+
+	mc, ok := conformance.methods[key]
+	if !ok {
+		mc = &methodConformance{Specification: specification, Method: name, Operation: method.OperationName}
+		conformance.methods[key] = mc
+	}
+	mc.Responses++
+
+	if len(violations) > 0 {
+		now := time.Now()
+		mc.NonConforming++
+		mc.LastStatus = status
+		mc.LastViolations = violations
+		mc.LastSeen = &now
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Serves the conformance of each method called through the proxy, those with the
+// most non-conforming responses first.
+func (conformance *Conformance) handler(w http.ResponseWriter, req *http.Request) {
+	conformance.Lock()
+	methods := make([]methodConformance, 0, len(conformance.methods))
+	for _, mc := range conformance.methods {
+		methods = append(methods, *mc)
+	}
+	conformance.Unlock()
+
+	sort.Slice(methods, func(i, j int) bool {
+		if methods[i].NonConforming != methods[j].NonConforming {
+			return methods[i].NonConforming > methods[j].NonConforming
+		}
+		if methods[i].Specification != methods[j].Specification {
+			return methods[i].Specification < methods[j].Specification
+		}
+		return methods[i].Method < methods[j].Method
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(map[string]interface{}{"methods": methods}); err != nil {
+		logger.Errorf(req, "Error encoding conformance: %s", err)
+	}
+}
+
+// -----------------------------------------------------------------------------
@@ -18,13 +18,16 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package proxy
 
 import (
+	"context"
 	"github.com/dapperdox/dapperdox/config"
 	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/metrics"
+	"github.com/dapperdox/dapperdox/spec"
 	"github.com/gorilla/pat"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"strings"
+	"sort"
 	"time"
 )
 
@@ -40,26 +43,52 @@ func (r *responseCapture) WriteHeader(status int) {
 
 // -----------------------------------------------------------------------------
 
-func Register(r *pat.Router) {
-	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
-
+// Register creates the configured proxied paths. Proxied requests are validated, and
+// responses checked for conformance, against the methods documented by suite, or by
+// the specification the path is configured for. The latency and upstream status of
+// proxied requests are recorded in m.
+func Register(r *pat.Router, cfg *config.Config, suite map[string]*spec.APISpecification, conformance *Conformance, m *metrics.Metrics) {
 	logger.Tracef(nil, "Registering proxied paths:\n")
 
-	for i := range cfg.ProxyPath {
-		slice := strings.Split(cfg.ProxyPath[i], "=")
-		switch len(slice) {
-		case 2:
-			register(r, slice[0], slice[1])
-		default:
-			panic("Invalid ProxyPath specified - does not contain an = delimited path=host/path pair")
+	registered := make(map[string]bool)
+
+	// Paths proxied for a specification of its own are checked against it alone, and
+	// take precedence over those proxied for every specification.
+	var ids []string
+	for id := range suite {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		specification := suite[id]
+		if sc, ok := cfg.SpecConfig(id, specification.URL); !ok || sc.ProxyPath == nil {
+			continue
+		}
+		for _, p := range cfg.ForSpec(id, specification.URL).Proxies() {
+			if registered[p.Path] {
+				logger.Errorf(nil, "Proxy path %s of specification %s is already proxied", p.Path, id)
+				continue
+			}
+			registered[p.Path] = true
+			register(r, cfg, map[string]*spec.APISpecification{id: specification}, conformance, m, p.Path, p.Target)
+		}
+	}
+
+	for _, p := range cfg.Proxies() {
+		if !registered[p.Path] {
+			register(r, cfg, suite, conformance, m, p.Path, p.Target)
 		}
 	}
+	if cfg.ProxyConformance {
+		r.Path("/conformance.json").Methods("GET").HandlerFunc(conformance.handler)
+	}
 	logger.Tracef(nil, "Registering proxied paths done.\n")
 }
 
 // -----------------------------------------------------------------------------
 
-func register(r *pat.Router, routePattern string, target string) {
+func register(r *pat.Router, cfg *config.Config, suite map[string]*spec.APISpecification, conformance *Conformance, m *metrics.Metrics, routePattern string, target string) {
 
 	u, _ := url.Parse(target)
 
@@ -79,7 +108,32 @@ func register(r *pat.Router, routePattern string, target string) {
 		logger.Debugf(r, "Proxy request to: %s%s%s", scheme, r.Host, r.URL.Path)
 	}
 
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		m.ObserveUpstream(routePattern, resp.StatusCode)
+		if cfg.ProxyConformance {
+			return conformance.check(resp)
+		}
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logger.Errorf(r, "Proxy request to %s failed: %s", target, err)
+		m.ObserveUpstreamError(routePattern)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
 	r.PathPrefix(routePattern).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.ProxyValidate || cfg.ProxyConformance {
+			method, params, specification := findMethod(suite, r, u)
+			if method == nil {
+				logger.Tracef(r, "Proxy request %s %s is not documented, so not checked", r.Method, r.URL.Path)
+			} else {
+				if cfg.ProxyValidate && !validateRequest(w, r, method, params) {
+					return
+				}
+				r = r.WithContext(context.WithValue(r.Context(), methodKey, documentedMethod{specification, method}))
+			}
+		}
+
 		rc := &responseCapture{w, 0}
 		s := time.Now()
 		logger.Tracef(r, "Proxy request started: %v", s)
@@ -91,6 +145,7 @@ func register(r *pat.Router, routePattern string, target string) {
 
 		d := e.Sub(s)
 		logger.Infof(r, "PROXY %s %s (%d, %v)", r.Method, r.URL.Path, rc.statusCode, d)
+		m.ObserveProxy(routePattern, d)
 	})
 }
 
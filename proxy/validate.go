@@ -0,0 +1,113 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// maxRequestBody is the largest request body read to be validated. Larger requests
+// with a body that is validated are rejected, rather than buffered. Bodies that are
+// not validated are proxied as they are read.
+const maxRequestBody = 10 << 20
+
+// contractError is the body of the response to a request that violates the
+// documented contract of the method it calls
+type contractError struct {
+	Error      string           `json:"error"`
+	Method     string           `json:"method"` // The documented method, such as "GET /pets/{id}"
+	Operation  string           `json:"operation,omitempty"`
+	Violations []spec.Violation `json:"violations"`
+}
+
+// -----------------------------------------------------------------------------
+// Validates a request against the documented method it calls. If the request
+// violates the documented contract, a 400 response describing each violation is
+// written and false returned, so that the request is not proxied.
+func validateRequest(w http.ResponseWriter, r *http.Request, method *spec.Method, params map[string]string) bool {
+
+	var body []byte
+	if spec.ValidatesBody(r.Header.Get("Content-Type")) {
+		var err error
+		body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+		r.Body.Close()
+		if err != nil {
+			if int64(len(body)) >= maxRequestBody {
+				logger.Infof(r, "PROXY %s %s rejected as its body is larger than %d bytes", r.Method, r.URL.Path, maxRequestBody)
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return false
+			}
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return false
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body)) // Put the body back to be proxied
+	}
+
+	violations := method.ValidateRequest(r, params, body)
+	if len(violations) == 0 {
+		return true
+	}
+
+	for _, v := range violations {
+		logger.Debugf(r, "Proxy request violation: %s", v)
+	}
+	logger.Infof(r, "PROXY %s %s rejected with %d violations of the documented contract", r.Method, r.URL.Path, len(violations))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(contractError{
+		Error:      "The request does not conform to the documented contract of " + methodName(method),
+		Method:     methodName(method),
+		Operation:  method.OperationName,
+		Violations: violations,
+	})
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// Returns the documented method a proxied request calls. The method path is matched
+// against the request path, which is usually the same as the path on the target,
+// else against the path on the target. The ID of the specification documenting the
+// method is also returned.
+func findMethod(suite map[string]*spec.APISpecification, r *http.Request, target *url.URL) (*spec.Method, map[string]string, string) {
+	if method, params, specification := spec.FindMethod(suite, r.Method, r.URL.Path); method != nil {
+		return method, params, specification
+	}
+	if target.Path != "" && target.Path != "/" {
+		return spec.FindMethod(suite, r.Method, path.Join(target.Path, r.URL.Path))
+	}
+	return nil, nil, ""
+}
+
+func methodName(method *spec.Method) string {
+	return strings.ToUpper(method.Method) + " " + method.Path
+}
+
+// -----------------------------------------------------------------------------
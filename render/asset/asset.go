@@ -35,39 +35,63 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"unicode"
 )
 
-var _bindata = map[string][]byte{}
-var _metadata = map[string]map[string]string{}
-var guideReplacer *strings.Replacer
-var gfmReplace []*gfmReplacer
-
 var sectionSplitRegex = regexp.MustCompile("\\[\\[[\\w\\-\\/]+\\]\\]")
 var gfmMapSplit = regexp.MustCompile(":")
 
+// Store holds the compiled assets, keyed by name
+type Store struct {
+	cfg           *config.Config
+	bindata       map[string][]byte
+	metadata      map[string]map[string]string
+	guideReplacer *strings.Replacer
+	gfmReplace    []*gfmReplacer
+	staticPrefix  string
+}
+
 // ---------------------------------------------------------------------------
-func Asset(name string) ([]byte, error) {
+// NewStore returns an empty store, for the assets configured by cfg.
+func NewStore(cfg *config.Config) *Store {
+	var replacements []string
+
+	// Configure the replacer, to search/replace Document URLs
+	for _, rw := range cfg.DocumentRewrites() {
+		replacements = append(replacements, rw.From, rw.To)
+	}
+
+	return &Store{
+		cfg:           cfg,
+		bindata:       map[string][]byte{},
+		metadata:      map[string]map[string]string{},
+		guideReplacer: strings.NewReplacer(replacements...),
+	}
+}
+
+// ---------------------------------------------------------------------------
+func (s *Store) Asset(name string) ([]byte, error) {
 	cannonicalName := strings.Replace(name, "\\", "/", -1)
-	if a, ok := _bindata[cannonicalName]; ok {
+	if a, ok := s.bindata[cannonicalName]; ok {
 		return a, nil
 	}
 	return nil, fmt.Errorf("Asset %s not found", name)
 }
 
 // ---------------------------------------------------------------------------
-func AssetNames() []string {
-	names := make([]string, 0, len(_bindata))
-	for name := range _bindata {
+func (s *Store) AssetNames() []string {
+	names := make([]string, 0, len(s.bindata))
+	for name := range s.bindata {
 		names = append(names, name)
 	}
 	return names
 }
 
 // ---------------------------------------------------------------------------
-func MetaData(filename string, name string) string {
-	if md, ok := _metadata[filename]; ok {
+func (s *Store) MetaData(filename string, name string) string {
+	if md, ok := s.metadata[filename]; ok {
 		if val, ok := md[strings.ToLower(name)]; ok {
 			return val
 		}
@@ -76,10 +100,10 @@ func MetaData(filename string, name string) string {
 }
 
 // ---------------------------------------------------------------------------
-func MetaDataFileList() []string {
-	files := make([]string, len(_metadata))
+func (s *Store) MetaDataFileList() []string {
+	files := make([]string, len(s.metadata))
 	ix := 0
-	for key := range _metadata {
+	for key := range s.metadata {
 		files[ix] = key
 		ix++
 	}
@@ -87,37 +111,20 @@ func MetaDataFileList() []string {
 }
 
 // ---------------------------------------------------------------------------
-func Compile(dir string, prefix string) {
-
-	cfg, _ := config.Get()
-
-	// Build a replacer to search/replace Document URLs in the documents.
-	if guideReplacer == nil {
-		var replacements []string
-
-		// Configure the replacer with key=value pairs
-		for i := range cfg.DocumentRewriteURL {
-
-			slice := strings.Split(cfg.DocumentRewriteURL[i], "=")
-
-			if len(slice) != 2 {
-				panic("Invalid DocumentWriteUrl - does not contain an = delimited from=to pair")
-			}
-			replacements = append(replacements, slice...)
-		}
-		guideReplacer = strings.NewReplacer(replacements...)
-	}
+// Compile stores the assets in dir under prefix, returning an error if an asset
+// cannot be compiled. A file removed while dir is being scanned is skipped.
+func (s *Store) Compile(dir string, prefix string) error {
 
 	dir, err := filepath.Abs(dir)
 	if err != nil {
-		logger.Errorf(nil, "Error forming absolute path: %s", err)
+		return fmt.Errorf("error forming absolute path: %s", err)
 	}
 
 	logger.Debugf(nil, "- Scanning directory %s", dir)
 
 	dir = filepath.ToSlash(dir)
 
-	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		path = filepath.Clean(filepath.ToSlash(path))
 
 		if info == nil {
@@ -138,13 +145,17 @@ func Compile(dir string, prefix string) {
 		}
 
 		buf, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			logger.Debugf(nil, "  * Skipping %s, removed while compiling", path)
+			return nil
+		}
 		if err != nil {
-			panic(err)
+			return err
 		}
 
 		relative, err := filepath.Rel(dir, path)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
 		var meta map[string]string
@@ -164,32 +175,30 @@ func Compile(dir string, prefix string) {
 				sections, headings := splitOnSection(string(buf))
 
 				if sections == nil {
-					logger.Errorf(nil, "  * Error no sections defined in overlay file %s\n", relative)
-					os.Exit(1)
+					return fmt.Errorf("no sections defined in overlay file %s", path)
 				}
 
 				for i, heading := range headings {
-					buf = ProcessMarkdown([]byte(sections[i]))
+					buf = s.ProcessMarkdown([]byte(sections[i]))
 
 					relative = filepath.Join(mdname, heading, "overlay.tmpl")
-					storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
+					s.storeTemplate(prefix, relative, s.guideReplacer.Replace(string(buf)), meta)
 				}
 			} else {
-				buf = ProcessMarkdown(buf) // Convert markdown into HTML
+				buf = s.ProcessMarkdown(buf) // Convert markdown into HTML
 
 				relative = mdname + ".tmpl"
-				storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
+				s.storeTemplate(prefix, relative, s.guideReplacer.Replace(string(buf)), meta)
 			}
 		case ".tmpl":
 			buf, meta = ProcessMetadata(buf)
-			storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
+			s.storeTemplate(prefix, relative, s.guideReplacer.Replace(string(buf)), meta)
 
 		case ".html":
-			logger.Errorf(nil, "  * Error - Refusing to process .html files. Expects HTML template fragments with .tmpl extension. File %s\n", relative)
-			os.Exit(1)
+			return fmt.Errorf("refusing to process .html file %s, expected HTML template fragments with a .tmpl extension", path)
 
 		default:
-			storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
+			s.storeTemplate(prefix, relative, s.guideReplacer.Replace(string(buf)), meta)
 		}
 
 		return nil
@@ -198,28 +207,65 @@ func Compile(dir string, prefix string) {
 
 // ---------------------------------------------------------------------------
 
-func storeTemplate(prefix string, name string, template string, meta map[string]string) {
+func (s *Store) storeTemplate(prefix string, name string, template string, meta map[string]string) {
 
 	newname := filepath.ToSlash(filepath.Join(prefix, name))
 
-	if _, ok := _bindata[newname]; !ok {
+	if _, ok := s.bindata[newname]; !ok {
 		logger.Debugf(nil, "  + Import %s", newname)
 		// Store the template, doing and search/replaces on the way
-		_bindata[newname] = []byte(template)
+		s.bindata[newname] = []byte(template)
 		if len(meta) > 0 {
 			logger.Tracef(nil, "    + Adding metadata")
-			_metadata[newname] = meta
+			s.metadata[newname] = meta
 		}
 	}
 }
 
+// ---------------------------------------------------------------------------
+// PrefixStatic serves the static assets beneath prefix, rewriting the site-absolute
+// links to them in the templates and stylesheets, so that they do not clash with the
+// static assets of another store.
+func (s *Store) PrefixStatic(prefix string) {
+	var paths []string
+	for name := range s.bindata {
+		if strings.HasPrefix(name, "assets/static/") {
+			paths = append(paths, strings.TrimPrefix(name, "assets/static"))
+		}
+	}
+	// Longest first, so that a path is not taken for one it begins with
+	sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+
+	var replacements []string
+	for _, path := range paths {
+		for _, quote := range []string{`"`, `'`, `(`} {
+			replacements = append(replacements, quote+path, quote+prefix+path)
+		}
+	}
+	replacer := strings.NewReplacer(replacements...)
+
+	for name, b := range s.bindata {
+		switch filepath.Ext(name) {
+		case ".tmpl", ".css":
+			s.bindata[name] = []byte(replacer.Replace(string(b)))
+		}
+	}
+	s.staticPrefix = prefix
+}
+
+// ---------------------------------------------------------------------------
+// StaticPrefix returns the path the static assets are served beneath.
+func (s *Store) StaticPrefix() string {
+	return s.staticPrefix
+}
+
 // ---------------------------------------------------------------------------
 // Returns rendered markdown
-func ProcessMarkdown(doc []byte) []byte {
+func (s *Store) ProcessMarkdown(doc []byte) []byte {
 
 	html := github_flavored_markdown.Markdown([]byte(doc))
 	// Apply any HTML substitutions
-	for _, rep := range gfmReplace {
+	for _, rep := range s.gfmReplace {
 		html = rep.Regexp.ReplaceAll(html, rep.Replace)
 	}
 	return html
@@ -298,11 +344,11 @@ func splitOnSection(text string) ([]string, []string) {
 
 // ---------------------------------------------------------------------------
 
-func CompileGFMMap() {
+func (s *Store) CompileGFMMap() {
 
 	var mapfile string
 
-	cfg, _ := config.Get()
+	cfg := s.cfg
 
 	if len(cfg.AssetsDir) != 0 {
 		mapfile = filepath.Join(cfg.AssetsDir, "gfm.map")
@@ -354,7 +400,7 @@ func CompileGFMMap() {
 		rep := &gfmReplacer{}
 		if rep.Parse(line) != nil {
 			logger.Tracef(nil, "GFM replace %s with %s\n", rep.Regexp, rep.Replace)
-			gfmReplace = append(gfmReplace, rep)
+			s.gfmReplace = append(s.gfmReplace, rep)
 		}
 	}
 
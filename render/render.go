@@ -34,41 +34,91 @@ import (
 	"github.com/unrolled/render"
 )
 
-// Render is a global instance of github.com/unrolled/render.Render
-var Render *render.Render
-
 //var guides interface{}
 type GuideType []*navigation.NavigationNode
 type overlayPathList []string
 
-var guides = map[string]GuideType{} // Guides are per specification-id, or 'top-level'
+// Renderer renders the pages of the documentation, from the compiled assets, for a
+// suite of specifications
+type Renderer struct {
+	cfg      *config.Config
+	suite    map[string]*spec.APISpecification
+	failures map[string]*spec.APISpecification
+	assets   *asset.Store
+	render   *render.Render
+	guides   map[string]GuideType // Guides are per specification-id, or 'top-level'
+	specs    map[string]*Renderer // Renderers of specifications with settings of their own
+	counter  int
+}
 
 // Vars is a map of variables
 type Vars map[string]interface{}
 
-var counter int
-
 // ----------------------------------------------------------------------------------------
-
-func Register() {
-	Render = New()
+// New compiles the assets configured by cfg, returning a Renderer of the documentation
+// of suite. Specifications that failed to load are given in failures. An error is
+// returned if the assets cannot be compiled.
+func New(cfg *config.Config, suite map[string]*spec.APISpecification, failures map[string]*spec.APISpecification) (*Renderer, error) {
+	r, err := newRenderer(cfg, suite, failures, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.specs = map[string]*Renderer{}
+
+	// Specifications configured with a theme, document rewrites or guides of their own
+	// are rendered from assets compiled for them.
+	for id, specification := range suite {
+		sc, ok := cfg.SpecConfig(id, specification.URL)
+		if !ok || (sc.Theme == "" && sc.ThemeDir == "" && sc.GuidesDir == "" && sc.DocumentRewriteURL == nil) {
+			continue
+		}
+		logger.Debugf(nil, "- Assets for specification '%s'", id)
+		if r.specs[id], err = newRenderer(cfg.ForSpec(id, specification.URL), suite, failures, specification); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
 }
 
 // ----------------------------------------------------------------------------------------
-// New creates a new instance of github.com/unrolled/render.Render
-func New() *render.Render {
+// Returns a Renderer of the assets configured by cfg. If a specification is given, the
+// Renderer is of its pages alone, and its guides directory takes precedence over the
+// guides in the assets.
+func newRenderer(cfg *config.Config, suite map[string]*spec.APISpecification, failures map[string]*spec.APISpecification, specification *spec.APISpecification) (*Renderer, error) {
 	logger.Tracef(nil, "creating instance of render.Render")
 
-	cfg, _ := config.Get()
+	r := &Renderer{
+		cfg:      cfg,
+		suite:    suite,
+		failures: failures,
+		assets:   asset.NewStore(cfg),
+		guides:   map[string]GuideType{},
+	}
 
-	asset.CompileGFMMap()
+	r.assets.CompileGFMMap()
+
+	// Directories are compiled until one fails, the error of which is returned
+	var err error
+	compile := func(dir string, prefix string) {
+		if err == nil {
+			err = r.assets.Compile(dir, prefix)
+		}
+	}
+
+	if specification != nil {
+		if sc, _ := cfg.SpecConfig(specification.ID, specification.URL); len(sc.GuidesDir) != 0 {
+			compile(sc.GuidesDir, "assets/templates/"+specification.ID+"/templates/guides")
+		}
+	}
 
 	// XXX Order of directory importing is IMPORTANT XXX
 	if len(cfg.AssetsDir) != 0 {
-		asset.Compile(cfg.AssetsDir+"/templates", "assets/templates")
-		asset.Compile(cfg.AssetsDir+"/static", "assets/static")
-		asset.Compile(cfg.AssetsDir+"/themes/"+cfg.Theme, "assets")
-		compileSections(cfg.AssetsDir)
+		compile(cfg.AssetsDir+"/templates", "assets/templates")
+		compile(cfg.AssetsDir+"/static", "assets/static")
+		compile(cfg.AssetsDir+"/themes/"+cfg.Theme, "assets")
+		if err == nil {
+			err = r.compileSections(cfg.AssetsDir)
+		}
 	}
 
 	// Import custom theme from custom directory (if defined)
@@ -77,22 +127,38 @@ func New() *render.Render {
 		if len(cfg.ThemeDir) != 0 {
 			dir = cfg.ThemeDir
 		}
-		asset.Compile(dir+"/"+cfg.Theme, "assets")
+		compile(dir+"/"+cfg.Theme, "assets")
 	}
 
 	if cfg.Theme != "default" {
 		// The default theme underpins all others
-		asset.Compile(cfg.DefaultAssetsDir+"/themes/default", "assets")
+		compile(cfg.DefaultAssetsDir+"/themes/default", "assets")
 	}
 
 	// Fallback to local templates directory
-	asset.Compile(cfg.DefaultAssetsDir+"/templates", "assets/templates")
+	compile(cfg.DefaultAssetsDir+"/templates", "assets/templates")
 	// Fallback to local static directory
-	asset.Compile(cfg.DefaultAssetsDir+"/static", "assets/static")
+	compile(cfg.DefaultAssetsDir+"/static", "assets/static")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if specification != nil {
+		// Served beneath the specification, as the assets may differ from those of the site
+		r.assets.PrefixStatic("/" + specification.ID)
+	}
+
+	r.render = render.New(r.options())
+	return r, nil
+}
 
-	return render.New(render.Options{
-		Asset:      asset.Asset,
-		AssetNames: asset.AssetNames,
+// ----------------------------------------------------------------------------------------
+// Returns the options of a github.com/unrolled/render.Render of the compiled assets.
+func (r *Renderer) options() render.Options {
+	return render.Options{
+		Asset:      r.assets.Asset,
+		AssetNames: r.assets.AssetNames,
 		Directory:  "assets/templates",
 		Delims:     render.Delims{Left: "[:", Right: ":]"},
 		Layout:     "layout",
@@ -105,32 +171,55 @@ func New() *render.Render {
 			"uc":            strings.ToUpper,
 			"join":          strings.Join,
 			"concat":        func(a, b string) string { return a + b },
-			"counter_set":   func(a int) int { counter = a; return counter },
-			"counter_add":   func(a int) int { counter += a; return counter },
+			"counter_set":   func(a int) int { r.counter = a; return r.counter },
+			"counter_add":   func(a int) int { r.counter += a; return r.counter },
 			"mod":           func(a int, m int) int { return a % m },
 			"safehtml":      func(s string) template.HTML { return template.HTML(s) },
-			"haveTemplate":  func(n string) *template.Template { return TemplateLookup(n) },
-			"overlay":       func(n string, d ...interface{}) template.HTML { return overlay(n, d) },
+			"haveTemplate":  func(n string) *template.Template { return r.TemplateLookup(n) },
+			"overlay":       func(n string, d ...interface{}) template.HTML { return r.overlay(n, d) },
 			"getAssetPaths": func(s string, d ...interface{}) []string { return getAssetPaths(s, d) },
 		}},
-	})
+	}
 }
 
 // ----------------------------------------------------------------------------------------
+// For returns the Renderer of the pages of apiSpec. This is r itself, unless the
+// specification is configured with a theme, document rewrites or guides of its own.
+func (r *Renderer) For(apiSpec *spec.APISpecification) *Renderer {
+	if apiSpec != nil {
+		if rnd, ok := r.specs[apiSpec.ID]; ok {
+			return rnd
+		}
+	}
+	return r
+}
 
-func compileSections(assetsDir string) {
+// ----------------------------------------------------------------------------------------
+// Assets returns the compiled assets.
+func (r *Renderer) Assets() *asset.Store {
+	return r.assets
+}
+
+// ----------------------------------------------------------------------------------------
+
+func (r *Renderer) compileSections(assetsDir string) error {
 	// specification specific guides
-	for _, specification := range spec.APISuite {
+	for _, specification := range r.suite {
 		logger.Debugf(nil, "- Specification assets for '%s'", specification.APIInfo.Title)
-		compileSectionPart(assetsDir, specification, "templates", "assets/templates/")
-		compileSectionPart(assetsDir, specification, "static", "assets/static/")
+		if err := r.compileSectionPart(assetsDir, specification, "templates", "assets/templates/"); err != nil {
+			return err
+		}
+		if err := r.compileSectionPart(assetsDir, specification, "static", "assets/static/"); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // ----------------------------------------------------------------------------------------
-func compileSectionPart(assetsDir string, spec *spec.APISpecification, part string, prefix string) {
+func (r *Renderer) compileSectionPart(assetsDir string, spec *spec.APISpecification, part string, prefix string) error {
 	stem := spec.ID + "/" + part
-	asset.Compile(assetsDir+"/sections/"+stem, prefix+stem)
+	return r.assets.Compile(assetsDir+"/sections/"+stem, prefix+stem)
 }
 
 // ----------------------------------------------------------------------------------------
@@ -144,7 +233,7 @@ func (w HTMLWriter) Write(data []byte) (int, error) { return w.h.Write(data) }
 func (w HTMLWriter) Flush()                         { w.h.Flush() }
 
 // XXX WHY ARRAY of DATA?
-func overlay(name string, data []interface{}) template.HTML { // TODO Will be specification specific
+func (r *Renderer) overlay(name string, data []interface{}) template.HTML { // TODO Will be specification specific
 
 	if data == nil || data[0] == nil {
 		logger.Printf(nil, "Data nil\n")
@@ -168,7 +257,7 @@ func overlay(name string, data []interface{}) template.HTML { // TODO Will be sp
 	// Look for an overlay file in declaration order.... Highest priority is first.
 	for _, overlay = range overlayName {
 		logger.Tracef(nil, "Overlay: Does '%s' exist?\n", overlay)
-		if TemplateLookup(overlay) != nil {
+		if r.TemplateLookup(overlay) != nil {
 			break
 		}
 		overlay = ""
@@ -178,9 +267,10 @@ func overlay(name string, data []interface{}) template.HTML { // TODO Will be sp
 		logger.Tracef(nil, "Applying overlay '%s'\n", overlay)
 		writer := HTMLWriter{h: bufio.NewWriter(&b)}
 
-		r := New()
+		// The page is still being rendered, so the overlay needs a render of its own
+		rnd := render.New(r.options())
 		// data is a single item array (though I've not figured out why yet!)
-		r.HTML(writer, http.StatusOK, overlay, data[0], render.HTMLOptions{Layout: ""})
+		rnd.HTML(writer, http.StatusOK, overlay, data[0], render.HTMLOptions{Layout: ""})
 		writer.Flush()
 	}
 
@@ -190,7 +280,6 @@ func overlay(name string, data []interface{}) template.HTML { // TODO Will be sp
 // ----------------------------------------------------------------------------------------
 
 func overlayPaths(name string, datamap map[string]interface{}) []string {
-
 	var overlayName []string
 
 	// Use the passed in data structures to determine what type of "page" we are on:
@@ -222,42 +311,54 @@ func overlayPaths(name string, datamap map[string]interface{}) []string {
 
 // ----------------------------------------------------------------------------------------
 // HTML is an alias to github.com/unrolled/render.Render.HTML
-func HTML(w http.ResponseWriter, status int, name string, binding interface{}, htmlOpt ...render.HTMLOptions) {
-	Render.HTML(w, status, name, binding, htmlOpt...)
+func (r *Renderer) HTML(w http.ResponseWriter, status int, name string, binding interface{}, htmlOpt ...render.HTMLOptions) {
+	r.render.HTML(w, status, name, binding, htmlOpt...)
 }
 
 // ----------------------------------------------------------------------------------------
-func TemplateLookup(t string) *template.Template {
-	return Render.TemplateLookup(t)
+// JSON is an alias to github.com/unrolled/render.Render.JSON
+func (r *Renderer) JSON(w http.ResponseWriter, status int, v interface{}) {
+	r.render.JSON(w, status, v)
+}
+
+// ----------------------------------------------------------------------------------------
+func (r *Renderer) TemplateLookup(t string) *template.Template {
+	return r.render.TemplateLookup(t)
 }
 
 // ----------------------------------------------------------------------------------------
 // DefaultVars adds the default vars (config, specs, others....) to the data map
-func DefaultVars(req *http.Request, apiSpec *spec.APISpecification, m Vars) map[string]interface{} {
+func (r *Renderer) DefaultVars(req *http.Request, apiSpec *spec.APISpecification, m Vars) map[string]interface{} {
 	if m == nil {
 		logger.Traceln(req, "creating new template data map")
 		m = make(map[string]interface{})
 	}
 
-	cfg, _ := config.Get()
+	cfg := r.cfg
 	m["Config"] = cfg
-	m["APISuite"] = spec.APISuite
+	m["APISuite"] = r.suite
+	m["APIFailures"] = r.failures
 
 	// If we have a multiple specifications or are forcing a parent "root" page for the single specification
 	// then set MultipleSpecs to true to enable navigation back to the root page.
-	if cfg.ForceSpecList || len(spec.APISuite) > 1 {
+	if cfg.ForceSpecList || len(r.suite)+len(r.failures) > 1 {
 		m["MultipleSpecs"] = true
 	}
 
+	// When exporting a static site there is no server, so no proxied paths are available.
+	if len(cfg.ExportDir) != 0 {
+		m["Exported"] = true
+	}
+
 	if apiSpec == nil {
-		m["NavigationGuides"] = guides[""] // Global guides
+		m["NavigationGuides"] = r.guides[""] // Global guides
 		m["SpecPath"] = ""
 
 		return m
 	}
 
 	// Per specification defaults
-	m["NavigationGuides"] = guides[apiSpec.ID]
+	m["NavigationGuides"] = r.guides[apiSpec.ID]
 
 	m["ID"] = apiSpec.ID
 	m["SpecPath"] = "/" + apiSpec.ID
@@ -266,17 +367,18 @@ func DefaultVars(req *http.Request, apiSpec *spec.APISpecification, m Vars) map[
 	m["Resources"] = apiSpec.ResourceList
 	m["Info"] = apiSpec.APIInfo
 	m["SpecURL"] = apiSpec.URL
+	m["Revisions"] = apiSpec.Revisions
 
 	return m
 }
 
 // ----------------------------------------------------------------------------------------
-func SetGuidesNavigation(apiSpec *spec.APISpecification, guidesnav *[]*navigation.NavigationNode) {
+func (r *Renderer) SetGuidesNavigation(apiSpec *spec.APISpecification, guidesnav *[]*navigation.NavigationNode) {
 	id := ""
 	if apiSpec != nil {
 		id = apiSpec.ID
 	}
-	guides[id] = *guidesnav
+	r.guides[id] = *guidesnav
 }
 
 // ----------------------------------------------------------------------------------------
@@ -11,4 +11,5 @@
     #-tls-key=server.rsa.key \
     #-author-show-assets=true \
     #-assets-dir=./examples/overlay/assets \
-    #-proxy-path=/developer=https://developer.some-dev-site.com 
+    #-proxy-path=/developer=https://developer.some-dev-site.com \
+    #-watch=true 
@@ -0,0 +1,436 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package search
+
+// This package provides a full-text search index of the reference documentation and
+// guides. Each page is indexed as a document holding the weighted terms of its title,
+// path and body text. Queries match documents containing every query term, or a term
+// that it prefixes, and hits are ranked by the sum of the weights of the matched terms.
+
+import (
+	"html"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render/asset"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// Kinds of document in the index
+const (
+	KindAPI      = "api"
+	KindMethod   = "method"
+	KindResource = "resource"
+	KindGuide    = "guide"
+)
+
+// Term weights by where the term was found
+const (
+	titleWeight = 10
+	pathWeight  = 5
+	bodyWeight  = 1
+)
+
+const snippetLength = 200
+
+var htmlTag = regexp.MustCompile(`(?s)<[^>]*>`)
+var templateAction = regexp.MustCompile(`(?s)\[:.*?:\]`)
+var heading = regexp.MustCompile(`(?is)<h1[^>]*>(.*?)</h1>`)
+
+// Document is a page in the index
+type Document struct {
+	Kind          string
+	Title         string
+	URL           string
+	Specification string // Title of the specification the page belongs to, if any
+	Text          string // Plain body text, from which snippets are taken
+	terms         map[string]int
+}
+
+// Hit is a document matching a query
+type Hit struct {
+	Kind          string  `json:"kind"`
+	Title         string  `json:"title"`
+	URL           string  `json:"url"`
+	Specification string  `json:"specification,omitempty"`
+	Snippet       string  `json:"snippet"`
+	Score         float64 `json:"score"`
+}
+
+// Index is a searchable set of documents
+type Index struct {
+	documents []*Document
+}
+
+// ---------------------------------------------------------------------------
+// Build indexes the reference documentation of every specification in suite, and the
+// guides compiled into the assets returned by assets for each specification, or for
+// the top level guides when given nil.
+func Build(suite map[string]*spec.APISpecification, assets func(specification *spec.APISpecification) *asset.Store) *Index {
+	idx := &Index{}
+
+	for _, specification := range suite {
+		idx.addSpecification(specification)
+		idx.addGuides(assets(specification), specification)
+	}
+	idx.addGuides(assets(nil), nil)
+
+	logger.Infof(nil, "Search index built with %d documents", len(idx.documents))
+	return idx
+}
+
+// ---------------------------------------------------------------------------
+
+func (idx *Index) addSpecification(specification *spec.APISpecification) {
+	specID := "/" + specification.ID
+	title := specification.APIInfo.Title
+
+	for _, api := range specification.APIs {
+		apiURL := specID + "/reference/" + api.ID
+
+		d := idx.add(KindAPI, api.Name, apiURL, title)
+		for _, method := range api.Methods {
+			d.index(method.Name, bodyWeight)
+			d.index(method.Path, bodyWeight)
+		}
+
+		for _, method := range api.Methods {
+			d := idx.add(KindMethod, method.Name, apiURL+"/"+method.ID, title)
+			if d.Title == "" {
+				d.Title = strings.ToUpper(method.Method) + " " + method.Path
+			}
+			d.index(method.OperationName, titleWeight)
+			d.index(method.Method+" "+method.Path, pathWeight)
+			d.addText(method.Description)
+
+			for _, params := range [][]spec.Parameter{method.PathParams, method.QueryParams, method.HeaderParams, method.FormParams} {
+				for _, p := range params {
+					d.index(p.Name, pathWeight)
+					d.addText(p.Description)
+				}
+			}
+			if method.BodyParam != nil {
+				d.index(method.BodyParam.Name, pathWeight)
+				d.addText(method.BodyParam.Description)
+			}
+		}
+	}
+
+	// Resources are documented once, however many versions there are
+	seen := make(map[string]bool)
+	for _, resources := range specification.ResourceList {
+		for id, resource := range resources {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			d := idx.add(KindResource, resource.Title, specID+"/resources/"+id, title)
+			if d.Title == "" {
+				d.Title = id
+			}
+			d.index(id, titleWeight)
+			d.addText(resource.Description)
+			d.addProperties(resource, 0)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Indexes the properties of a resource, and those of its nested resources, as body text.
+func (d *Document) addProperties(resource *spec.Resource, depth int) {
+	if depth > 8 { // Guard against recursive models
+		return
+	}
+	for name, property := range resource.Properties {
+		d.index(name, pathWeight)
+		d.addText(property.Description)
+		d.addProperties(property, depth+1)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Indexes guides, using the same mapping of asset to route as the guides handler.
+func (idx *Index) addGuides(assets *asset.Store, specification *spec.APISpecification) {
+	pathBase := "assets/templates/guides"
+	routeBase := "/guides"
+	specTitle := ""
+
+	if specification != nil {
+		pathBase = "assets/templates/" + specification.ID + "/templates/guides"
+		routeBase = "/" + specification.ID + "/guides"
+		specTitle = specification.APIInfo.Title
+	}
+
+	for _, name := range assets.AssetNames() {
+		if !strings.HasPrefix(name, pathBase) || filepath.Ext(name) != ".tmpl" {
+			continue
+		}
+		body, err := assets.Asset(name)
+		if err != nil {
+			continue
+		}
+
+		route := routeBase + strings.TrimSuffix(strings.TrimPrefix(name, pathBase), filepath.Ext(name))
+
+		d := idx.add(KindGuide, guideTitle(assets, name, body), route, specTitle)
+		d.addText(string(body))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// A guide's title is its first heading, else the last part of its navigation
+// metadata, else its filename.
+func guideTitle(assets *asset.Store, name string, body []byte) string {
+	if m := heading.FindSubmatch(body); m != nil {
+		if title := plainText(string(m[1])); title != "" {
+			return title
+		}
+	}
+	if nav := assets.MetaData(name, "Navigation"); nav != "" {
+		parts := strings.Split(nav, "/")
+		return parts[len(parts)-1]
+	}
+	return strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+}
+
+// ---------------------------------------------------------------------------
+
+func (idx *Index) add(kind string, title string, url string, specification string) *Document {
+	d := &Document{
+		Kind:          kind,
+		Title:         plainText(title),
+		URL:           url,
+		Specification: specification,
+		terms:         make(map[string]int),
+	}
+	d.index(d.Title, titleWeight)
+	d.index(url, pathWeight)
+
+	idx.documents = append(idx.documents, d)
+	return d
+}
+
+func (d *Document) addText(s string) {
+	text := plainText(s)
+	if text == "" {
+		return
+	}
+	d.index(text, bodyWeight)
+
+	if d.Text != "" {
+		d.Text += " "
+	}
+	d.Text += text
+}
+
+func (d *Document) index(s string, weight int) {
+	for _, term := range terms(s) {
+		d.terms[term] += weight
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Search returns the documents matching query, best first. At most limit hits are
+// returned, or all of them if limit is not positive.
+func (idx *Index) Search(query string, limit int) []Hit {
+	hits := []Hit{}
+
+	if idx == nil {
+		return hits
+	}
+	queryTerms := terms(query)
+	if len(queryTerms) == 0 {
+		return hits
+	}
+
+	for _, d := range idx.documents {
+		score := 0
+		for _, qt := range queryTerms {
+			s := d.score(qt)
+			if s == 0 {
+				score = 0
+				break
+			}
+			score += s
+		}
+		if score == 0 {
+			continue
+		}
+		hits = append(hits, Hit{
+			Kind:          d.Kind,
+			Title:         d.Title,
+			URL:           d.URL,
+			Specification: d.Specification,
+			Snippet:       snippet(d.Text, queryTerms),
+			Score:         float64(score),
+		})
+	}
+
+	sort.Sort(byScore(hits))
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// ---------------------------------------------------------------------------
+// Scores a query term against a document. Exact matches score their full weight,
+// and terms the query term is a prefix of score half of theirs.
+func (d *Document) score(queryTerm string) int {
+	score := 0
+	for term, weight := range d.terms {
+		if term == queryTerm {
+			score += weight * 2
+		} else if strings.HasPrefix(term, queryTerm) {
+			score += weight
+		}
+	}
+	return score
+}
+
+// ---------------------------------------------------------------------------
+// Returns the text around the first occurrence of a query term.
+func snippet(text string, queryTerms []string) string {
+	if len(text) <= snippetLength {
+		return text
+	}
+
+	start := 0
+	for _, qt := range queryTerms {
+		if i := indexFold(text, qt); i >= 0 {
+			start = i - snippetLength/4
+			break
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	// Start and end on word boundaries, or at least on rune boundaries for text, such
+	// as CJK, that has no spaces.
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	if start > 0 {
+		if i := strings.IndexByte(text[start:], ' '); i >= 0 {
+			start += i + 1
+		}
+	}
+	end := start + snippetLength
+	if end >= len(text) {
+		end = len(text)
+	} else if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+		end = start + i
+	} else {
+		for end > start && !utf8.RuneStart(text[end]) {
+			end--
+		}
+	}
+
+	s := text[start:end]
+	if start > 0 {
+		s = "..." + s
+	}
+	if end < len(text) {
+		s += "..."
+	}
+	return s
+}
+
+// ---------------------------------------------------------------------------
+// Returns the index of the first occurrence of substr in s, ignoring case, or -1.
+// Case is folded rune by rune, as the lower case of a rune need not be the same
+// length in UTF-8, so an index into strings.ToLower(s) may not be an index into s.
+func indexFold(s string, substr string) int {
+	n := utf8.RuneCountInString(substr)
+	for i := range s {
+		j := i
+		for k := 0; k < n && j < len(s); k++ {
+			_, size := utf8.DecodeRuneInString(s[j:])
+			j += size
+		}
+		if strings.EqualFold(s[i:j], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ---------------------------------------------------------------------------
+// Splits s into lower case terms. Paths, identifiers and camel case names are split
+// into words.
+func terms(s string) []string {
+	var result []string
+	var word []rune
+
+	flush := func() {
+		if len(word) > 1 {
+			result = append(result, string(word))
+		}
+		word = word[:0]
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			// Split camelCase, but not acronyms such as ID
+			if i > 0 && unicode.IsLower(runes[i-1]) {
+				flush()
+			}
+			word = append(word, unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return result
+}
+
+// ---------------------------------------------------------------------------
+// Converts HTML, or a template, to plain text.
+func plainText(s string) string {
+	s = templateAction.ReplaceAllString(s, " ")
+	s = htmlTag.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// ---------------------------------------------------------------------------
+
+type byScore []Hit
+
+func (h byScore) Len() int      { return len(h) }
+func (h byScore) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h byScore) Less(i, j int) bool {
+	if h[i].Score != h[j].Score {
+		return h[i].Score > h[j].Score
+	}
+	return h[i].Title < h[j].Title
+}
+
+// ---------------------------------------------------------------------------
@@ -0,0 +1,84 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package search
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSnippetNonASCII(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{
+			// Ⱥ is two bytes in UTF-8, but its lower case ⱥ is three
+			name:  "lower case longer than upper",
+			text:  strings.Repeat("Ⱥ ", 200) + "needle " + strings.Repeat("Ⱥ ", 200),
+			terms: []string{"needle"},
+			want:  "needle",
+		},
+		{
+			name:  "match folds case",
+			text:  strings.Repeat("Ⱥ ", 200) + "ȺNeedle " + strings.Repeat("Ⱥ ", 200),
+			terms: []string{"ⱥneedle"},
+			want:  "ȺNeedle",
+		},
+		{
+			name:  "text without spaces",
+			text:  strings.Repeat("日本語の文書", 50) + "検索" + strings.Repeat("日本語の文書", 50),
+			terms: []string{"検索"},
+			want:  "検索",
+		},
+	}
+
+	for _, test := range tests {
+		s := snippet(test.text, test.terms)
+		if !utf8.ValidString(s) {
+			t.Errorf("%s: snippet %q is not valid UTF-8", test.name, s)
+		}
+		if !strings.Contains(s, test.want) {
+			t.Errorf("%s: snippet %q does not contain %q", test.name, s, test.want)
+		}
+	}
+}
+
+func TestIndexFold(t *testing.T) {
+	tests := []struct {
+		s      string
+		substr string
+		want   int
+	}{
+		{"Hello World", "world", 6},
+		{"ȺȺ needle", "needle", 5},
+		{"ȺȺ", "ⱥ", 0},
+		{"日本語", "語", 6},
+		{"abc", "d", -1},
+		{"ab", "abc", -1},
+	}
+
+	for _, test := range tests {
+		if got := indexFold(test.s, test.substr); got != test.want {
+			t.Errorf("indexFold(%q, %q) = %d, want %d", test.s, test.substr, got, test.want)
+		}
+	}
+}
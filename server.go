@@ -0,0 +1,337 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package dapperdox
+
+// This package serves the documentation of a suite of OpenAPI specifications. A Server
+// owns the specifications it loads, the assets compiled to render them and the routes
+// serving them, so that it can be mounted within another Go service, and several
+// servers run in one process.
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/export"
+	"github.com/dapperdox/dapperdox/handlers/changelog"
+	"github.com/dapperdox/dapperdox/handlers/guides"
+	"github.com/dapperdox/dapperdox/handlers/home"
+	"github.com/dapperdox/dapperdox/handlers/model"
+	"github.com/dapperdox/dapperdox/handlers/reference"
+	"github.com/dapperdox/dapperdox/handlers/search"
+	"github.com/dapperdox/dapperdox/handlers/specs"
+	"github.com/dapperdox/dapperdox/handlers/static"
+	"github.com/dapperdox/dapperdox/handlers/timeout"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/metrics"
+	"github.com/dapperdox/dapperdox/mock"
+	"github.com/dapperdox/dapperdox/proxy"
+	"github.com/dapperdox/dapperdox/render"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/dapperdox/dapperdox/watcher"
+	"github.com/gorilla/pat"
+	"github.com/justinas/alice"
+	"github.com/justinas/nosurf"
+)
+
+// Options configures a Server
+type Options struct {
+	Config     *config.Config // The defaults of config.New are used if nil
+	Version    string         // Given in the Server header of responses
+	Background bool           // Load the specifications after New returns, not ready until done
+}
+
+// Server is an http.Handler serving the documentation of the configured specifications
+type Server struct {
+	cfg         *config.Config
+	version     string
+	conformance *proxy.Conformance // Kept across reloads
+	metrics     *metrics.Metrics   // Kept across reloads
+	proxyPaths  []string           // Paths proxied, globally or for a specification
+	handler     http.Handler
+
+	loaded chan struct{} // Closed once the specifications are first loaded
+	mu     sync.RWMutex
+	site   *site
+}
+
+// site is the documentation built from the specifications and assets. A site is not
+// changed once built, so a reload builds a new site to replace it, while requests
+// already being served by the current site complete.
+type site struct {
+	router   *pat.Router
+	renderer *render.Renderer
+	loaded   int // Specifications documented
+	failed   int // Specifications that failed to load
+}
+
+// ---------------------------------------------------------------------------
+// New loads the configured specifications and compiles the assets, returning a
+// Server of their documentation. An error is returned if the configuration is not
+// valid, or the assets cannot be compiled. Given opts.Background, New returns once
+// the configuration is checked, and the Server answers 503 Service Unavailable until
+// the specifications have loaded.
+func New(opts Options) (*Server, error) {
+	cfg := config.New()
+	if opts.Config != nil {
+		c := *opts.Config // The configuration is not changed by the caller once in use
+		cfg = &c
+	}
+	if len(cfg.SpecFilename) == 0 {
+		cfg.SpecFilename = []string{"/swagger.json"}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		cfg:         cfg,
+		version:     opts.Version,
+		conformance: proxy.NewConformance(),
+		metrics:     metrics.New(),
+		proxyPaths:  proxyPaths(cfg),
+		loaded:      make(chan struct{}),
+	}
+	s.handler = alice.New(logger.Observe(s.observe) /*, context.ClearHandler*/, s.timeoutHandler, s.withCsrf, s.injectHeaders).Then(http.HandlerFunc(s.serveSite))
+
+	if opts.Background {
+		go func() {
+			if err := s.load(false); err != nil {
+				logger.Errorf(nil, "Load error: %s", err)
+			}
+		}()
+	} else if err := s.load(false); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ---------------------------------------------------------------------------
+// ServeHTTP serves the documentation, along with the /healthz, /readyz and
+// /metrics endpoints, which are neither logged nor counted.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/healthz":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	case "/readyz":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !s.ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("loading specifications\n"))
+			return
+		}
+		w.Write([]byte("ok\n"))
+	case "/metrics":
+		s.metrics.ServeHTTP(w, req)
+	default:
+		s.handler.ServeHTTP(w, req)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Reload loads the specifications and compiles the assets afresh, replacing the
+// documentation served once they have loaded. The current documentation continues
+// to be served meanwhile, and is kept if the assets cannot be compiled.
+func (s *Server) Reload() {
+	logger.Infof(nil, "Reloading specifications and assets")
+	if err := s.load(true); err != nil {
+		logger.Errorf(nil, "Reload error, the current documentation is kept: %s", err)
+		return
+	}
+	logger.Infof(nil, "Reload complete")
+}
+
+// ---------------------------------------------------------------------------
+// Watch watches the specification, assets and theme directories, reloading when they
+// change, once the specifications have first loaded. Watch never returns, so is
+// normally run as a goroutine.
+func (s *Server) Watch(interval time.Duration) {
+	<-s.loaded
+	watcher.Watch(s.watchDirs(), interval, s.Reload)
+}
+
+// ---------------------------------------------------------------------------
+// Export writes the documentation into dir as a static site.
+func (s *Server) Export(dir string) error {
+	<-s.loaded
+	site := s.current()
+	if site == nil {
+		return fmt.Errorf("the documentation failed to load")
+	}
+	return export.Site(site.router, dir, mock.Prefix(s.cfg))
+}
+
+// ---------------------------------------------------------------------------
+// Builds the site and serves it in place of the current one, recording the load. If
+// the site cannot be built, the current site continues to be served.
+func (s *Server) load(reload bool) error {
+	if !reload {
+		defer close(s.loaded)
+	}
+
+	site, err := s.build()
+	if err != nil {
+		return err
+	}
+	s.metrics.ObserveLoad(reload, site.loaded, site.failed)
+
+	s.mu.Lock()
+	s.site = site
+	s.mu.Unlock()
+
+	return nil
+}
+
+// The Server is ready once it has a site to serve.
+func (s *Server) ready() bool {
+	return s.current() != nil
+}
+
+// ---------------------------------------------------------------------------
+// Loads the specifications and compiles the assets, registering every route of the
+// documentation with a new router.
+func (s *Server) build() (*site, error) {
+	router := pat.New()
+	loader := spec.NewLoader(s.cfg)
+
+	suite, failures := loader.LoadSuite(true)
+	specs.Register(router, s.cfg, loader, suite)
+
+	rnd, err := render.New(s.cfg, suite, failures)
+	if err != nil {
+		return nil, err
+	}
+
+	reference.Register(router, rnd, suite)
+	guides.Register(router, rnd, suite)
+	changelog.Register(router, rnd, suite)
+	search.Register(router, rnd, suite)
+	model.Register(router, rnd, suite)
+	mock.Register(router, s.cfg, suite)
+	static.Register(router, rnd, suite) // TODO - Static content should be capable of being CDN hosted
+
+	home.Register(router, s.cfg, rnd, suite, failures)
+	proxy.Register(router, s.cfg, suite, s.conformance, s.metrics)
+
+	return &site{router: router, renderer: rnd, loaded: len(suite), failed: len(failures)}, nil
+}
+
+// ---------------------------------------------------------------------------
+
+func (s *Server) current() *site {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.site
+}
+
+func (s *Server) serveSite(w http.ResponseWriter, req *http.Request) {
+	site := s.current()
+	if site == nil {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "DapperDox is loading specifications", http.StatusServiceUnavailable)
+		return
+	}
+	site.router.ServeHTTP(w, req)
+}
+
+// Renders an error page, or a plain error while the specifications first load.
+func (s *Server) renderError(w http.ResponseWriter, status int, message string) {
+	site := s.current()
+	if site == nil {
+		http.Error(w, message, status)
+		return
+	}
+	site.renderer.HTML(w, status, "error", map[string]interface{}{"error": message})
+}
+
+// ---------------------------------------------------------------------------
+// Returns the directories to watch for changes in watch mode.
+func (s *Server) watchDirs() []string {
+	cfg := s.cfg
+
+	var dirs []string
+
+	if len(cfg.SpecDir) != 0 {
+		dirs = append(dirs, cfg.SpecDir)
+	}
+	if len(cfg.AssetsDir) != 0 {
+		dirs = append(dirs, cfg.AssetsDir)
+	}
+	if len(cfg.ThemeDir) != 0 {
+		dirs = append(dirs, cfg.ThemeDir)
+	}
+	for _, key := range cfg.SpecConfigs() {
+		sc, _ := cfg.SpecConfig(key, "")
+		for _, dir := range []string{sc.ThemeDir, sc.GuidesDir} {
+			if len(dir) != 0 {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	dirs = append(dirs, cfg.DefaultAssetsDir+"/themes")
+
+	return dirs
+}
+
+// ---------------------------------------------------------------------------
+func (s *Server) withCsrf(h http.Handler) http.Handler {
+	csrfHandler := nosurf.New(h)
+
+	// Mock methods are called by applications, which have no CSRF token to send
+	if len(s.cfg.MockPrefix) != 0 {
+		csrfHandler.ExemptRegexp("^/?" + regexp.QuoteMeta(strings.Trim(s.cfg.MockPrefix, "/")) + "/")
+	}
+	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rsn := nosurf.Reason(req).Error()
+		logger.Warnf(req, "failed csrf validation: %s", rsn)
+		s.renderError(w, http.StatusBadRequest, rsn)
+	}))
+	return csrfHandler
+}
+
+// ---------------------------------------------------------------------------
+func (s *Server) timeoutHandler(h http.Handler) http.Handler {
+	return timeout.Handler(h, 1*time.Second, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		logger.Warnln(req, "request timed out")
+		s.renderError(w, http.StatusRequestTimeout, "Request timed out")
+	}))
+}
+
+// ---------------------------------------------------------------------------
+// Handle additional headers such as strict transport security for TLS, and
+// giving the Server name.
+func (s *Server) injectHeaders(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Server", strings.TrimSpace("DapperDox "+s.version))
+
+		if r.TLS != nil {
+			w.Header().Add("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,460 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// CodeSample is an example request for a method, in a given language
+type CodeSample struct {
+	Lang   string // Language, also used to select syntax highlighting
+	Label  string // Name of the sample to display
+	Source string
+}
+
+// A request to be written as a code sample. Values the reader must supply are
+// {placeholders}, named after the parameter or credential.
+type sampleRequest struct {
+	method      string
+	url         string
+	headers     [][2]string
+	contentType string
+	body        string // JSON or other text body
+	form        []sampleField
+	multipart   bool
+	basicAuth   bool
+}
+
+type sampleField struct {
+	name   string
+	value  string
+	isFile bool
+}
+
+// -----------------------------------------------------------------------------
+// Returns the code samples for a method. Samples given by the operation's
+// x-codeSamples extension replace those generated from the method.
+func (c *APISpecification) codeSamples(method *Method, o *spec.Operation, pointer string) []CodeSample {
+
+	if ext, ok := o.Extensions["x-codeSamples"]; ok {
+		samples, err := specCodeSamples(ext)
+		if err == nil {
+			return samples
+		}
+		c.addWarning(pointer+"/x-codeSamples", "Invalid x-codeSamples: %s", err)
+	}
+
+	r := newSampleRequest(method)
+
+	return []CodeSample{
+		{Lang: "shell", Label: "curl", Source: r.curl()},
+		{Lang: "go", Label: "Go", Source: r.golang()},
+		{Lang: "python", Label: "Python", Source: r.python()},
+		{Lang: "javascript", Label: "JavaScript", Source: r.javascript()},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Parses x-codeSamples, a list of objects with lang, label and source members.
+func specCodeSamples(ext interface{}) ([]CodeSample, error) {
+	list, ok := ext.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of code samples")
+	}
+
+	var samples []CodeSample
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("code sample %d is not an object", i)
+		}
+		lang, _ := m["lang"].(string)
+		source, _ := m["source"].(string)
+		if lang == "" || source == "" {
+			return nil, fmt.Errorf("code sample %d must have lang and source members", i)
+		}
+		label, _ := m["label"].(string)
+		if label == "" {
+			label = lang
+		}
+		samples = append(samples, CodeSample{Lang: lang, Label: label, Source: source})
+	}
+	return samples, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func newSampleRequest(method *Method) *sampleRequest {
+	r := &sampleRequest{method: strings.ToUpper(method.Method)}
+
+	path := method.Path
+	for _, p := range method.PathParams {
+		if len(p.Enum) > 0 {
+			path = strings.Replace(path, "{"+p.Name+"}", p.Enum[0], -1)
+		}
+	}
+
+	var query []string
+	for _, p := range method.QueryParams {
+		if p.Required {
+			query = append(query, p.Name+"="+sampleValue(p))
+		}
+	}
+	for _, p := range method.HeaderParams {
+		if p.Required {
+			r.headers = append(r.headers, [2]string{p.Name, sampleValue(p)})
+		}
+	}
+
+	// Credentials, in a stable order
+	var schemes []string
+	for name := range method.Security {
+		schemes = append(schemes, name)
+	}
+	sort.Strings(schemes)
+
+	for _, name := range schemes {
+		scheme := method.Security[name].Scheme
+		switch {
+		case scheme == nil:
+		case scheme.IsBasic:
+			r.basicAuth = true
+		case scheme.IsApiKey && scheme.ParamLocation == "query":
+			query = append(query, scheme.ParamName+"={"+scheme.ParamName+"}")
+		case scheme.IsApiKey:
+			r.headers = append(r.headers, [2]string{scheme.ParamName, "{" + scheme.ParamName + "}"})
+		case scheme.IsOAuth2:
+			r.headers = append(r.headers, [2]string{"Authorization", "Bearer {access_token}"})
+		}
+	}
+
+	r.url = path
+	if method.APIGroup != nil && method.APIGroup.URL != nil && method.APIGroup.URL.Host != "" {
+		r.url = method.APIGroup.URL.String() + path
+	}
+	if len(query) > 0 {
+		r.url += "?" + strings.Join(query, "&")
+	}
+
+	if len(method.Produces) > 0 {
+		r.headers = append(r.headers, [2]string{"Accept", method.Produces[0]})
+	}
+
+	switch {
+	case method.BodyParam != nil:
+		r.contentType = preferredContentType(method.Consumes, "application/json")
+		if method.BodyParam.Resource != nil {
+			r.body = method.BodyParam.Resource.Example
+			if method.BodyParam.IsArray {
+				r.body = exampleArray(r.body)
+			}
+		}
+	case len(method.FormParams) > 0:
+		r.contentType = preferredContentType(method.Consumes, "application/x-www-form-urlencoded")
+		for _, p := range method.FormParams {
+			isFile := len(p.Type) > 0 && p.Type[len(p.Type)-1] == "file"
+			if isFile {
+				r.multipart = true
+			}
+			r.form = append(r.form, sampleField{name: p.Name, value: sampleValue(p), isFile: isFile})
+		}
+		if strings.HasPrefix(r.contentType, "multipart/") {
+			r.multipart = true
+		}
+	}
+
+	// Multipart bodies carry their own content type, with the part boundary
+	if r.contentType != "" && !r.multipart {
+		r.headers = append(r.headers, [2]string{"Content-Type", r.contentType})
+	}
+
+	return r
+}
+
+// -----------------------------------------------------------------------------
+
+func sampleValue(p Parameter) string {
+	if len(p.Enum) > 0 {
+		return p.Enum[0]
+	}
+	return "{" + p.Name + "}"
+}
+
+func preferredContentType(consumes []string, preferred string) string {
+	for _, c := range consumes {
+		if c == preferred {
+			return c
+		}
+	}
+	if len(consumes) > 0 {
+		return consumes[0]
+	}
+	return preferred
+}
+
+// -----------------------------------------------------------------------------
+
+func (r *sampleRequest) curl() string {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "curl -X %s %s", r.method, shellQuote(r.url))
+
+	if r.basicAuth {
+		fmt.Fprintf(&b, " \\\n  -u %s", shellQuote("{username}:{password}"))
+	}
+	for _, h := range r.headers {
+		fmt.Fprintf(&b, " \\\n  -H %s", shellQuote(h[0]+": "+h[1]))
+	}
+	for _, f := range r.form {
+		switch {
+		case f.isFile:
+			fmt.Fprintf(&b, " \\\n  -F %s", shellQuote(f.name+"=@"+f.value))
+		case r.multipart:
+			fmt.Fprintf(&b, " \\\n  -F %s", shellQuote(f.name+"="+f.value))
+		default:
+			fmt.Fprintf(&b, " \\\n  --data-urlencode %s", shellQuote(f.name+"="+f.value))
+		}
+	}
+	if r.body != "" {
+		fmt.Fprintf(&b, " \\\n  -d %s", shellQuote(r.body))
+	}
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// -----------------------------------------------------------------------------
+
+func (r *sampleRequest) golang() string {
+	var b bytes.Buffer
+
+	imports := []string{"fmt", "io/ioutil", "net/http"}
+	body := "nil"
+
+	switch {
+	case r.multipart:
+		imports = append(imports, "bytes", "mime/multipart")
+		if r.hasFile() {
+			imports = append(imports, "io", "os")
+		}
+		body = "body"
+	case len(r.form) > 0:
+		imports = append(imports, "net/url", "strings")
+		body = "strings.NewReader(form.Encode())"
+	case r.body != "":
+		imports = append(imports, "strings")
+		body = "strings.NewReader(body)"
+	}
+	sort.Strings(imports)
+
+	b.WriteString("package main\n\nimport (\n")
+	for _, i := range imports {
+		fmt.Fprintf(&b, "\t%q\n", i)
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+
+	switch {
+	case r.multipart:
+		b.WriteString("\tbody := &bytes.Buffer{}\n\tw := multipart.NewWriter(body)\n")
+		for _, f := range r.form {
+			if f.isFile {
+				fmt.Fprintf(&b, "\tif f, err := os.Open(%s); err == nil {\n", strconv.Quote(f.value))
+				fmt.Fprintf(&b, "\t\tpart, _ := w.CreateFormFile(%s, f.Name())\n\t\tio.Copy(part, f)\n\t\tf.Close()\n\t}\n", strconv.Quote(f.name))
+			} else {
+				fmt.Fprintf(&b, "\tw.WriteField(%s, %s)\n", strconv.Quote(f.name), strconv.Quote(f.value))
+			}
+		}
+		b.WriteString("\tw.Close()\n\n")
+	case len(r.form) > 0:
+		b.WriteString("\tform := url.Values{}\n")
+		for _, f := range r.form {
+			fmt.Fprintf(&b, "\tform.Set(%s, %s)\n", strconv.Quote(f.name), strconv.Quote(f.value))
+		}
+		b.WriteString("\n")
+	case r.body != "":
+		fmt.Fprintf(&b, "\tbody := %s\n\n", goString(r.body))
+	}
+
+	fmt.Fprintf(&b, "\treq, err := http.NewRequest(%q, %s, %s)\n", r.method, strconv.Quote(r.url), body)
+	b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+
+	if r.basicAuth {
+		b.WriteString("\treq.SetBasicAuth(\"{username}\", \"{password}\")\n")
+	}
+	if r.multipart {
+		b.WriteString("\treq.Header.Set(\"Content-Type\", w.FormDataContentType())\n")
+	}
+	for _, h := range r.headers {
+		fmt.Fprintf(&b, "\treq.Header.Set(%s, %s)\n", strconv.Quote(h[0]), strconv.Quote(h[1]))
+	}
+
+	b.WriteString("\n\tresp, err := http.DefaultClient.Do(req)\n")
+	b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	b.WriteString("\tdefer resp.Body.Close()\n\n")
+	b.WriteString("\tb, _ := ioutil.ReadAll(resp.Body)\n")
+	b.WriteString("\tfmt.Println(resp.Status, string(b))\n}")
+
+	return b.String()
+}
+
+// Returns s as a Go raw string literal where possible, as these keep JSON readable.
+func goString(s string) string {
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// -----------------------------------------------------------------------------
+
+func (r *sampleRequest) python() string {
+	var b bytes.Buffer
+	var args []string
+
+	b.WriteString("import requests\n\n")
+
+	if len(r.headers) > 0 {
+		b.WriteString("headers = {\n")
+		for _, h := range r.headers {
+			fmt.Fprintf(&b, "    %s: %s,\n", strconv.Quote(h[0]), strconv.Quote(h[1]))
+		}
+		b.WriteString("}\n")
+		args = append(args, "headers=headers")
+	}
+
+	var data, files []sampleField
+	for _, f := range r.form {
+		if f.isFile {
+			files = append(files, f)
+		} else {
+			data = append(data, f)
+		}
+	}
+	if len(data) > 0 {
+		b.WriteString("data = {\n")
+		for _, f := range data {
+			fmt.Fprintf(&b, "    %s: %s,\n", strconv.Quote(f.name), strconv.Quote(f.value))
+		}
+		b.WriteString("}\n")
+		args = append(args, "data=data")
+	}
+	if len(files) > 0 {
+		b.WriteString("files = {\n")
+		for _, f := range files {
+			fmt.Fprintf(&b, "    %s: open(%s, \"rb\"),\n", strconv.Quote(f.name), strconv.Quote(f.value))
+		}
+		b.WriteString("}\n")
+		args = append(args, "files=files")
+	}
+	if r.body != "" {
+		if strings.Contains(r.body, "'''") {
+			fmt.Fprintf(&b, "body = %s\n", strconv.Quote(r.body))
+		} else {
+			fmt.Fprintf(&b, "body = r'''%s'''\n", r.body)
+		}
+		args = append(args, "data=body")
+	}
+	if r.basicAuth {
+		args = append(args, "auth=(\"{username}\", \"{password}\")")
+	}
+	if b.Len() > len("import requests\n\n") {
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "response = requests.request(%q, %s", r.method, strconv.Quote(r.url))
+	for _, arg := range args {
+		b.WriteString(", " + arg)
+	}
+	b.WriteString(")\nprint(response.status_code, response.text)")
+
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
+
+func (r *sampleRequest) javascript() string {
+	var b bytes.Buffer
+
+	switch {
+	case r.multipart:
+		b.WriteString("const body = new FormData();\n")
+		for _, f := range r.form {
+			if f.isFile {
+				fmt.Fprintf(&b, "body.append(%s, document.querySelector(\"input[type=file]\").files[0]);\n", strconv.Quote(f.name))
+			} else {
+				fmt.Fprintf(&b, "body.append(%s, %s);\n", strconv.Quote(f.name), strconv.Quote(f.value))
+			}
+		}
+		b.WriteString("\n")
+	case len(r.form) > 0:
+		b.WriteString("const body = new URLSearchParams({\n")
+		for _, f := range r.form {
+			fmt.Fprintf(&b, "  %s: %s,\n", strconv.Quote(f.name), strconv.Quote(f.value))
+		}
+		b.WriteString("});\n\n")
+	case r.body != "":
+		fmt.Fprintf(&b, "const body = %s;\n\n", jsString(r.body))
+	}
+
+	fmt.Fprintf(&b, "const response = await fetch(%s, {\n", strconv.Quote(r.url))
+	fmt.Fprintf(&b, "  method: %q,\n", r.method)
+	if len(r.headers) > 0 || r.basicAuth {
+		b.WriteString("  headers: {\n")
+		if r.basicAuth {
+			b.WriteString("    \"Authorization\": \"Basic \" + btoa(\"{username}:{password}\"),\n")
+		}
+		for _, h := range r.headers {
+			fmt.Fprintf(&b, "    %s: %s,\n", strconv.Quote(h[0]), strconv.Quote(h[1]))
+		}
+		b.WriteString("  },\n")
+	}
+	if r.multipart || len(r.form) > 0 || r.body != "" {
+		b.WriteString("  body,\n")
+	}
+	b.WriteString("});\nconsole.log(response.status, await response.text());")
+
+	return b.String()
+}
+
+// Returns s as a JavaScript template literal where possible, as these keep JSON readable.
+func jsString(s string) string {
+	if strings.ContainsAny(s, "`\\") || strings.Contains(s, "${") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+func (r *sampleRequest) hasFile() bool {
+	for _, f := range r.form {
+		if f.isFile {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,138 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// Severity of a problem found in a specification
+type Severity string
+
+const (
+	// SeverityError prevents a specification from being documented
+	SeverityError Severity = "error"
+	// SeverityWarning is reported, but the specification is still documented
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic describes a problem found while loading a specification
+type Diagnostic struct {
+	Spec     string   `json:"spec"`    // Location of the specification
+	Pointer  string   `json:"pointer"` // JSON pointer to the offending member of the specification
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Diagnostics is the list of problems found while loading a specification
+type Diagnostics []Diagnostic
+
+// -----------------------------------------------------------------------------
+
+func (d Diagnostic) String() string {
+	pointer := d.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return fmt.Sprintf("%s: %s#%s: %s", d.Severity, d.Spec, pointer, d.Message)
+}
+
+// -----------------------------------------------------------------------------
+// HasErrors returns true if any of the diagnostics is an error, rather than a warning.
+func (d Diagnostics) HasErrors() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// Errors returns the number of error diagnostics.
+func (d Diagnostics) Errors() int {
+	count := 0
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			count++
+		}
+	}
+	return count
+}
+
+// -----------------------------------------------------------------------------
+// Warnings returns the number of warning diagnostics.
+func (d Diagnostics) Warnings() int {
+	return len(d) - d.Errors()
+}
+
+// -----------------------------------------------------------------------------
+
+func (d Diagnostics) log() {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			logger.Errorf(nil, "%s\n", diag)
+		} else {
+			logger.Warnf(nil, "%s\n", diag)
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *APISpecification) addError(pointer string, format string, args ...interface{}) {
+	c.addDiagnostic(SeverityError, pointer, format, args...)
+}
+
+func (c *APISpecification) addWarning(pointer string, format string, args ...interface{}) {
+	c.addDiagnostic(SeverityWarning, pointer, format, args...)
+}
+
+func (c *APISpecification) addDiagnostic(severity Severity, pointer string, format string, args ...interface{}) {
+	diag := Diagnostic{
+		Spec:     c.URL,
+		Pointer:  pointer,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	// Operations are processed once for each of their tags, so may be reported more than once
+	for _, d := range c.Diagnostics {
+		if d == diag {
+			return
+		}
+	}
+	c.Diagnostics = append(c.Diagnostics, diag)
+}
+
+// -----------------------------------------------------------------------------
+// Returns the JSON pointer to the operation for a method and (basePath prefixed) path.
+func (c *APISpecification) operationPointer(path string, methodname string) string {
+	return "/paths/" + escapePointer(strings.TrimPrefix(path, c.basePath)) + "/" + methodname
+}
+
+// -----------------------------------------------------------------------------
+// Escapes a JSON pointer reference token, as per RFC 6901.
+func escapePointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+// -----------------------------------------------------------------------------
+// end
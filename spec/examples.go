@@ -0,0 +1,303 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/go-openapi/spec"
+)
+
+// Nesting beyond which examples are not generated, in case of recursive models
+const maxExampleDepth = 10
+
+// Example values for string formats
+var formatExamples = map[string]string{
+	"date":      "2017-07-21",
+	"date-time": "2017-07-21T17:32:28Z",
+	"uuid":      "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+	"email":     "user@example.com",
+	"hostname":  "example.com",
+	"uri":       "https://example.com",
+	"url":       "https://example.com",
+	"ipv4":      "192.0.2.1",
+	"ipv6":      "2001:db8::1",
+	"byte":      "ZXhhbXBsZQ==",
+	"binary":    "<binary>",
+	"password":  "********",
+}
+
+// -----------------------------------------------------------------------------
+// Generates an example JSON document for a resource that has no example of its
+// own. The resource's compiled properties are used, so the read-only properties
+// of request resources are already excluded.
+func (r *Resource) generateExample() string {
+	value := r.exampleValue(0)
+
+	// A resource that is an array of objects documents the object, as does its schema
+	if array, ok := value.([]interface{}); ok && len(array) == 1 {
+		if _, ok := array[0].(map[string]interface{}); ok {
+			value = array[0]
+		}
+	}
+
+	example, err := JSONMarshalIndent(value)
+	if err != nil {
+		logger.Errorf(nil, "Error encoding generated example json: %s", err)
+		return ""
+	}
+	return string(example)
+}
+
+// -----------------------------------------------------------------------------
+// Returns the example value of a resource. An example or default given by the
+// schema is used in preference to a generated one.
+func (r *Resource) exampleValue(depth int) interface{} {
+
+	var t string
+	if len(r.Type) > 0 {
+		t = strings.ToLower(r.Type[0])
+	}
+
+	switch t {
+	case "array":
+		// The schema is that of the array items
+		if r.schema != nil && r.schema.Example != nil {
+			return []interface{}{r.schema.Example}
+		}
+		if r.schema != nil && r.schema.Items != nil {
+			// Items that are themselves arrays are not compiled into properties
+			return []interface{}{schemaExample(r.schema, depth+1)}
+		}
+		if len(r.Type) > 1 && r.Type[1] != "object" {
+			return []interface{}{r.primitiveExample(r.Type[1])}
+		}
+		return []interface{}{r.objectExample(depth)}
+	case "map":
+		// The map's value type is its <key> property, so this gives { "key": value }
+		return r.objectExample(depth)
+	case "object":
+		if r.schema != nil && r.schema.Example != nil {
+			return r.schema.Example
+		}
+		if len(r.Properties) == 0 && len(r.Variants) > 0 {
+			// Polymorphic, with no properties common to the variants
+			return r.Variants[0].Resource.exampleValue(depth + 1)
+		}
+		return r.objectExample(depth)
+	}
+	return r.primitiveExample(t)
+}
+
+// -----------------------------------------------------------------------------
+
+func (r *Resource) objectExample(depth int) interface{} {
+	obj := make(map[string]interface{})
+
+	if depth >= maxExampleDepth {
+		return obj
+	}
+	for name, property := range r.Properties {
+		if name == "<key>" { // Member of a map
+			name = "key"
+		}
+		obj[name] = property.exampleValue(depth + 1)
+	}
+	return obj
+}
+
+// -----------------------------------------------------------------------------
+// Returns the example value of a schema that has not been compiled into a resource,
+// such as the items of a nested array.
+func schemaExample(s *spec.Schema, depth int) interface{} {
+	if s.Example != nil || depth >= maxExampleDepth {
+		return s.Example
+	}
+	if s.Items != nil {
+		items := s.Items.Schema
+		if items == nil && len(s.Items.Schemas) > 0 {
+			items = &s.Items.Schemas[0]
+		}
+		if items != nil {
+			return []interface{}{schemaExample(items, depth+1)}
+		}
+	}
+	if len(s.Properties) > 0 {
+		obj := make(map[string]interface{})
+		for name, property := range s.Properties {
+			property := property
+			obj[name] = schemaExample(&property, depth+1)
+		}
+		return obj
+	}
+
+	r := &Resource{schema: s}
+	if len(s.Type) > 0 {
+		return r.primitiveExample(s.Type[len(s.Type)-1])
+	}
+	return r.primitiveExample("object")
+}
+
+// -----------------------------------------------------------------------------
+// Returns an example of a primitive type. As the format replaces the type name in
+// the resource Type, t may be either.
+func (r *Resource) primitiveExample(t string) interface{} {
+
+	s := r.schema
+	if s != nil {
+		if s.Example != nil {
+			return s.Example
+		}
+		if s.Default != nil {
+			return s.Default
+		}
+		if len(s.Enum) > 0 {
+			return s.Enum[0]
+		}
+		if len(s.Format) > 0 {
+			t = s.Format
+		}
+	}
+
+	switch strings.ToLower(t) {
+	case "boolean":
+		return true
+	case "integer", "int32", "int64":
+		return int64(r.numberExample(1))
+	case "number", "float", "double":
+		return r.numberExample(1.5)
+	}
+
+	if example, ok := formatExamples[strings.ToLower(t)]; ok {
+		return example
+	}
+	return r.stringExample()
+}
+
+// -----------------------------------------------------------------------------
+// Returns n, moved within any minimum and maximum. Integers are kept whole.
+func (r *Resource) numberExample(n float64) float64 {
+	s := r.schema
+	if s == nil {
+		return n
+	}
+	whole := n == math.Trunc(n)
+	step := 1.0
+	if !whole {
+		step = 0.5
+	}
+
+	if s.Minimum != nil && s.Maximum != nil {
+		n = (*s.Minimum + *s.Maximum) / 2
+		if whole {
+			n = math.Floor(n)
+		}
+		return n
+	}
+	if s.Minimum != nil {
+		min := *s.Minimum
+		if whole {
+			min = math.Ceil(min)
+		}
+		if n < min || (n == min && s.ExclusiveMinimum) {
+			n = min
+			if s.ExclusiveMinimum {
+				n += step
+			}
+		}
+	}
+	if s.Maximum != nil {
+		max := *s.Maximum
+		if whole {
+			max = math.Floor(max)
+		}
+		if n > max || (n == max && s.ExclusiveMaximum) {
+			n = max
+			if s.ExclusiveMaximum {
+				n -= step
+			}
+		}
+	}
+	return n
+}
+
+// -----------------------------------------------------------------------------
+
+func (r *Resource) stringExample() string {
+	example := "string"
+
+	if s := r.schema; s != nil {
+		if s.MinLength != nil && int64(len(example)) < *s.MinLength {
+			example += strings.Repeat("s", int(*s.MinLength)-len(example))
+		}
+		if s.MaxLength != nil && int64(len(example)) > *s.MaxLength {
+			example = example[:*s.MaxLength]
+		}
+	}
+	return example
+}
+
+// -----------------------------------------------------------------------------
+// Example returns the example body of a response, or an empty string if it has no
+// resource.
+func (r *Response) Example() string {
+	if r.Resource == nil || r.Resource.Example == "" {
+		return ""
+	}
+	if r.IsArray {
+		return exampleArray(r.Resource.Example)
+	}
+	return r.Resource.Example
+}
+
+// -----------------------------------------------------------------------------
+// Example returns an example value of a response header: its default, else its
+// first possible value, else one generated from its type.
+func (h Header) Example() string {
+	if h.Default != "" {
+		return h.Default
+	}
+	if len(h.Enum) > 0 {
+		return h.Enum[0]
+	}
+	if len(h.Type) == 0 {
+		return ""
+	}
+	r := &Resource{}
+	return fmt.Sprintf("%v", r.primitiveExample(h.Type[len(h.Type)-1]))
+}
+
+// -----------------------------------------------------------------------------
+// Returns the example of a resource as an array of one member.
+func exampleArray(example string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(example), &v); err != nil {
+		return example
+	}
+	array, err := JSONMarshalIndent([]interface{}{v})
+	if err != nil {
+		return example
+	}
+	return string(array)
+}
+
+// -----------------------------------------------------------------------------
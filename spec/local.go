@@ -0,0 +1,119 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+)
+
+// A Loader loads the configured specifications. Local specifications are those in
+// the spec-dir, located by their path within it. They are read straight from disk, as
+// are the local documents they reference, with the spec-rewrite-url replacements
+// applied just as when they are served. Remote documents are fetched only once.
+type Loader struct {
+	cfg         *config.Config
+	replacer    *strings.Replacer
+	statusCodes map[int]string             // Descriptions of HTTP status codes
+	remote      map[string]json.RawMessage // Remote documents fetched, keyed by URL
+}
+
+// -----------------------------------------------------------------------------
+// NewLoader returns a Loader of the specifications configured by cfg.
+func NewLoader(cfg *config.Config) *Loader {
+	return &Loader{
+		cfg:         cfg,
+		replacer:    newReplacer(cfg),
+		statusCodes: loadStatusCodes(cfg),
+		remote:      make(map[string]json.RawMessage),
+	}
+}
+
+func newReplacer(cfg *config.Config) *strings.Replacer {
+	var replacements []string
+	for _, rw := range cfg.SpecRewrites() {
+		replacements = append(replacements, rw.From, rw.To)
+	}
+	return strings.NewReplacer(replacements...)
+}
+
+// -----------------------------------------------------------------------------
+// ForSpec returns the Loader of the specification with the ID and location given,
+// which applies the spec-rewrite-url replacements configured for it. This is l
+// itself, unless the specification is configured with replacements of its own.
+func (l *Loader) ForSpec(id string, location string) *Loader {
+	cfg := l.cfg.ForSpec(id, location)
+	if reflect.DeepEqual(cfg.SpecRewriteURL, l.cfg.SpecRewriteURL) {
+		return l
+	}
+	return &Loader{
+		cfg:         cfg,
+		replacer:    newReplacer(cfg),
+		statusCodes: l.statusCodes,
+		remote:      l.remote, // Replacements are not applied to remote documents
+	}
+}
+
+// -----------------------------------------------------------------------------
+// RewriteURLs applies the configured spec-rewrite-url replacements to a document.
+func (l *Loader) RewriteURLs(document []byte) []byte {
+	return []byte(l.replacer.Replace(string(document)))
+}
+
+// -----------------------------------------------------------------------------
+// Returns the file holding the local specification at specLocation, a path within
+// the spec-dir.
+func (l *Loader) localSpecPath(specLocation string) (string, error) {
+	if l.cfg.SpecDir == "" {
+		return "", fmt.Errorf("%s: no spec-dir is configured to load local specifications from", specLocation)
+	}
+	return filepath.Abs(filepath.Join(l.cfg.SpecDir, filepath.FromSlash(specLocation)))
+}
+
+// -----------------------------------------------------------------------------
+// Returns the file a document location refers to, if it is not a remote URL.
+// References from local documents are given as file URLs.
+func localFile(location string) (string, bool) {
+	if strings.HasPrefix(location, "file://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return strings.TrimPrefix(location, "file://"), true
+		}
+		return filepath.FromSlash(u.Path), true
+	}
+	return location, isLocalSpecUrl(location)
+}
+
+// -----------------------------------------------------------------------------
+
+func (l *Loader) readLocalDocument(file string) ([]byte, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return l.RewriteURLs(b), nil
+}
+
+// -----------------------------------------------------------------------------
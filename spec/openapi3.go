@@ -0,0 +1,781 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+// OpenAPI 3.x support.
+//
+// Rather than duplicate the whole of the specification parser, an OpenAPI 3.x document is
+// translated into the equivalent Swagger 2.0 document, which is then processed exactly as
+// any other Swagger specification. The translation maps:
+//
+//   servers                    -> schemes, host and basePath
+//   components/schemas         -> definitions
+//   components/securitySchemes -> securityDefinitions
+//   parameter schema           -> parameter type, format, items and collectionFormat
+//   requestBody                -> consumes, plus an 'in body' or formData parameters
+//   response content           -> produces, plus the response schema
+//
+// Where a request body or response offers several media types, all of them are listed
+// in consumes/produces, and the JSON media type (if there is one) supplies the schema.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+var oas3Operations = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+var serverVariable = regexp.MustCompile("{([^}]+)}")
+
+// -----------------------------------------------------------------------------
+// specVersion returns the version of the specification document, as declared by
+// its 'swagger' or 'openapi' member.
+func specVersion(raw json.RawMessage) (string, error) {
+	var header struct {
+		Swagger string `json:"swagger"`
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return "", err
+	}
+	if header.OpenAPI != "" {
+		return header.OpenAPI, nil
+	}
+	return header.Swagger, nil
+}
+
+// -----------------------------------------------------------------------------
+// convertOpenAPI3 translates an OpenAPI 3.x document into a Swagger 2.0 document.
+func convertOpenAPI3(raw json.RawMessage) (json.RawMessage, error) {
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	c := &oas3Converter{
+		doc:        doc,
+		components: asMap(doc["components"]),
+		consumes:   make(map[string]bool),
+		produces:   make(map[string]bool),
+	}
+
+	swagger := map[string]interface{}{
+		"swagger": "2.0",
+	}
+	copyMembers(swagger, doc, "info", "tags", "externalDocs", "security")
+	copyExtensions(swagger, doc)
+
+	c.convertServers(swagger)
+
+	if schemas := asMap(c.components["schemas"]); schemas != nil {
+		definitions := make(map[string]interface{})
+		for name, schema := range schemas {
+			definitions[name] = convertSchema(schema)
+		}
+		swagger["definitions"] = definitions
+	}
+
+	if schemes := asMap(c.components["securitySchemes"]); schemes != nil {
+		definitions := make(map[string]interface{})
+		for name, scheme := range schemes {
+			if def := c.convertSecurityScheme(name, asMap(c.resolve(scheme))); def != nil {
+				definitions[name] = def
+			}
+		}
+		swagger["securityDefinitions"] = definitions
+	}
+
+	paths := make(map[string]interface{})
+	for path, item := range asMap(doc["paths"]) {
+		paths[path] = c.convertPathItem(asMap(c.resolve(item)))
+	}
+	swagger["paths"] = paths
+
+	// The media types used across the whole specification become the defaults, so
+	// that an operation which does not declare a body or response still has a sensible
+	// set of content types.
+	if len(c.consumes) > 0 {
+		swagger["consumes"] = sortedKeys(c.consumes)
+	}
+	if len(c.produces) > 0 {
+		swagger["produces"] = sortedKeys(c.produces)
+	}
+
+	return json.Marshal(swagger)
+}
+
+// -----------------------------------------------------------------------------
+
+type oas3Converter struct {
+	doc        map[string]interface{}
+	components map[string]interface{}
+	consumes   map[string]bool
+	produces   map[string]bool
+}
+
+// -----------------------------------------------------------------------------
+// resolve follows a local component $ref (such as #/components/parameters/name),
+// returning the object it references. Schema references are left alone, as these
+// are expanded later along with the rest of the Swagger document.
+func (c *oas3Converter) resolve(v interface{}) interface{} {
+	for i := 0; i < 10; i++ { // Guard against circular references
+		m := asMap(v)
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/components/") || strings.HasPrefix(ref, "#/components/schemas/") {
+			return v
+		}
+		parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+		if len(parts) != 2 {
+			return v
+		}
+		target, ok := asMap(c.components[parts[0]])[unescapePointer(parts[1])]
+		if !ok {
+			logger.Errorf(nil, "Error: Unable to resolve reference %s\n", ref)
+			return v
+		}
+		v = target
+	}
+	return v
+}
+
+// -----------------------------------------------------------------------------
+// convertServers derives the Swagger schemes, host and basePath from the first
+// declared server. Server variables are replaced with their default values.
+func (c *oas3Converter) convertServers(swagger map[string]interface{}) {
+
+	servers := asSlice(c.doc["servers"])
+	if len(servers) == 0 {
+		return
+	}
+
+	var schemes []string
+	var host string
+
+	for i, s := range servers {
+		server := asMap(s)
+		u, err := url.Parse(expandServerVariables(asString(server["url"]), asMap(server["variables"])))
+		if err != nil {
+			logger.Errorf(nil, "Error: Invalid server url %s: %s\n", server["url"], err)
+			continue
+		}
+		if i == 0 {
+			host = u.Host
+			if host != "" {
+				swagger["host"] = host
+			}
+			if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+				swagger["basePath"] = path
+			}
+		}
+		// Collect the schemes of all servers that share the primary host
+		if u.Scheme != "" && u.Host == host && !contains(schemes, u.Scheme) {
+			schemes = append(schemes, u.Scheme)
+		}
+	}
+	if len(schemes) > 0 {
+		swagger["schemes"] = schemes
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func expandServerVariables(serverURL string, variables map[string]interface{}) string {
+	return serverVariable.ReplaceAllStringFunc(serverURL, func(m string) string {
+		name := m[1 : len(m)-1]
+		if def, ok := asMap(variables[name])["default"]; ok {
+			return fmt.Sprintf("%v", def)
+		}
+		return m
+	})
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *oas3Converter) convertSecurityScheme(name string, s map[string]interface{}) map[string]interface{} {
+
+	def := make(map[string]interface{})
+	copyMembers(def, s, "description")
+	copyExtensions(def, s)
+
+	switch asString(s["type"]) {
+	case "apiKey":
+		def["type"] = "apiKey"
+		copyMembers(def, s, "name", "in")
+		if def["in"] == "cookie" {
+			logger.Errorf(nil, "Error: Security scheme %s uses a cookie API key, which is not supported.\n", name)
+			return nil
+		}
+	case "http":
+		switch strings.ToLower(asString(s["scheme"])) {
+		case "basic":
+			def["type"] = "basic"
+		default:
+			// Bearer (and other) HTTP schemes are presented as an API key passed in the
+			// Authorization header.
+			def["type"] = "apiKey"
+			def["name"] = "Authorization"
+			def["in"] = "header"
+		}
+	case "oauth2":
+		def["type"] = "oauth2"
+		flows := asMap(s["flows"])
+
+		// Swagger 2.0 permits a single flow per scheme, so take the first in order of preference.
+		for _, f := range []struct{ oas3, swagger string }{
+			{"authorizationCode", "accessCode"},
+			{"implicit", "implicit"},
+			{"password", "password"},
+			{"clientCredentials", "application"},
+		} {
+			if flow := asMap(flows[f.oas3]); flow != nil {
+				def["flow"] = f.swagger
+				copyMembers(def, flow, "authorizationUrl", "tokenUrl", "scopes")
+				break
+			}
+		}
+	default:
+		logger.Errorf(nil, "Error: Security scheme %s has unsupported type %s\n", name, s["type"])
+		return nil
+	}
+	return def
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *oas3Converter) convertPathItem(item map[string]interface{}) map[string]interface{} {
+
+	pathItem := make(map[string]interface{})
+	copyExtensions(pathItem, item)
+
+	// Path level parameters are merged into each operation, which may override them.
+	common := c.convertParameters(asSlice(item["parameters"]))
+
+	for _, method := range oas3Operations {
+		if op := asMap(item[method]); op != nil {
+			operation := c.convertOperation(op)
+			operation["parameters"] = mergeParameters(common, asSlice(operation["parameters"]))
+			pathItem[method] = operation
+		}
+	}
+	return pathItem
+}
+
+// -----------------------------------------------------------------------------
+
+func mergeParameters(common []interface{}, params []interface{}) []interface{} {
+	merged := append([]interface{}{}, params...)
+
+	for _, p := range common {
+		cp := asMap(p)
+		overridden := false
+		for _, op := range params {
+			if asMap(op)["name"] == cp["name"] && asMap(op)["in"] == cp["in"] {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *oas3Converter) convertOperation(op map[string]interface{}) map[string]interface{} {
+
+	operation := make(map[string]interface{})
+	copyMembers(operation, op, "tags", "summary", "description", "externalDocs", "operationId", "deprecated", "security")
+	copyExtensions(operation, op)
+
+	params := c.convertParameters(asSlice(op["parameters"]))
+
+	if body := asMap(c.resolve(op["requestBody"])); body != nil {
+		content := asMap(body["content"])
+		mediaTypes := sortedKeys(content)
+		for _, mt := range mediaTypes {
+			c.consumes[mt] = true
+		}
+		operation["consumes"] = mediaTypes
+
+		mediaType := preferredMediaType(mediaTypes)
+		media := asMap(content[mediaType])
+
+		if isFormMediaType(mediaType) {
+			params = append(params, c.convertFormBody(media)...)
+		} else {
+			param := map[string]interface{}{
+				"name": "body",
+				"in":   "body",
+			}
+			copyMembers(param, body, "description", "required")
+			if schema, ok := media["schema"]; ok {
+				param["schema"] = withMediaExample(convertSchema(schema), media)
+			}
+			params = append(params, param)
+		}
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+
+	responses := make(map[string]interface{})
+	produces := make(map[string]bool)
+
+	for code, r := range asMap(op["responses"]) {
+		rsp := asMap(c.resolve(r))
+		response := make(map[string]interface{})
+		copyMembers(response, rsp, "description")
+		copyExtensions(response, rsp)
+
+		if _, ok := response["description"]; !ok {
+			response["description"] = ""
+		}
+
+		if headers := asMap(rsp["headers"]); headers != nil {
+			h := make(map[string]interface{})
+			for name, header := range headers {
+				h[name] = c.convertHeader(asMap(c.resolve(header)))
+			}
+			response["headers"] = h
+		}
+
+		content := asMap(rsp["content"])
+		mediaTypes := sortedKeys(content)
+		for _, mt := range mediaTypes {
+			produces[mt] = true
+			c.produces[mt] = true
+		}
+		if len(mediaTypes) > 0 {
+			media := asMap(content[preferredMediaType(mediaTypes)])
+			if schema, ok := media["schema"]; ok {
+				response["schema"] = withMediaExample(convertSchema(schema), media)
+			}
+		}
+		responses[code] = response
+	}
+	operation["responses"] = responses
+
+	if len(produces) > 0 {
+		operation["produces"] = sortedKeys(produces)
+	}
+
+	return operation
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *oas3Converter) convertParameters(params []interface{}) []interface{} {
+	var converted []interface{}
+
+	for _, p := range params {
+		param := asMap(c.resolve(p))
+		if param == nil {
+			continue
+		}
+		converted = append(converted, c.convertParameter(param))
+	}
+	return converted
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *oas3Converter) convertParameter(p map[string]interface{}) map[string]interface{} {
+
+	param := make(map[string]interface{})
+	copyMembers(param, p, "name", "in", "description", "required")
+	copyExtensions(param, p)
+
+	schema := c.resolveSchema(p["schema"], 0)
+	if schema == nil {
+		// Parameters described by content (rather than schema) are passed as strings
+		for _, mt := range sortedKeys(asMap(p["content"])) {
+			schema = c.resolveSchema(asMap(asMap(p["content"])[mt])["schema"], 0)
+			break
+		}
+	}
+	flattenSchema(param, schema)
+
+	if param["type"] == "array" {
+		param["collectionFormat"] = collectionFormatFromStyle(asString(p["in"]), asString(p["style"]), p["explode"])
+	}
+	return param
+}
+
+// -----------------------------------------------------------------------------
+// convertFormBody turns the properties of a form encoded request body into
+// formData parameters.
+func (c *oas3Converter) convertFormBody(media map[string]interface{}) []interface{} {
+
+	var params []interface{}
+
+	schema := c.resolveSchema(media["schema"], 0)
+	required := make(map[string]bool)
+	for _, r := range asSlice(schema["required"]) {
+		required[asString(r)] = true
+	}
+
+	properties := asMap(schema["properties"])
+	for _, name := range sortedKeys(properties) {
+		prop := c.resolveSchema(properties[name], 0)
+		param := map[string]interface{}{
+			"name":     name,
+			"in":       "formData",
+			"required": required[name],
+		}
+		copyMembers(param, prop, "description")
+		flattenSchema(param, prop)
+
+		if param["format"] == "binary" {
+			param["type"] = "file"
+			delete(param, "format")
+		}
+		if param["type"] == "array" {
+			param["collectionFormat"] = "multi"
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *oas3Converter) convertHeader(h map[string]interface{}) map[string]interface{} {
+
+	header := make(map[string]interface{})
+	copyMembers(header, h, "description")
+	flattenSchema(header, c.resolveSchema(h["schema"], 0))
+
+	if header["type"] == "array" {
+		header["collectionFormat"] = "csv"
+	}
+	return header
+}
+
+// -----------------------------------------------------------------------------
+// resolveSchema converts a schema, following a reference to a component schema, and
+// those of its items. Parameters and headers declare their type directly in Swagger
+// 2.0, rather than by reference, so need the schema referenced.
+func (c *oas3Converter) resolveSchema(v interface{}, depth int) map[string]interface{} {
+	schema := asMap(convertSchema(v))
+	if schema == nil || depth > 10 { // Guard against circular references
+		return schema
+	}
+	for i := 0; i < 10; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/definitions/") {
+			break
+		}
+		target, ok := asMap(c.components["schemas"])[unescapePointer(strings.TrimPrefix(ref, "#/definitions/"))]
+		if !ok {
+			logger.Errorf(nil, "Error: Unable to resolve reference %s\n", ref)
+			break
+		}
+		schema = asMap(convertSchema(target))
+	}
+	if items, ok := schema["items"]; ok {
+		schema["items"] = c.resolveSchema(items, depth+1)
+	}
+	return schema
+}
+
+// -----------------------------------------------------------------------------
+// flattenSchema copies the members of a schema that Swagger 2.0 declares directly
+// on parameters and headers.
+func flattenSchema(dst map[string]interface{}, schema map[string]interface{}) {
+	if schema == nil {
+		dst["type"] = "string"
+		return
+	}
+	copyMembers(dst, schema, "type", "format", "items", "default", "enum", "minimum", "maximum",
+		"exclusiveMinimum", "exclusiveMaximum", "minLength", "maxLength", "pattern", "minItems",
+		"maxItems", "uniqueItems", "multipleOf")
+
+	if _, ok := dst["type"]; !ok {
+		dst["type"] = "string"
+	}
+	if items := asMap(dst["items"]); items != nil {
+		if items["type"] == "array" {
+			items["collectionFormat"] = "csv"
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func collectionFormatFromStyle(in, style string, explode interface{}) string {
+	if style == "" {
+		switch in {
+		case "query", "cookie":
+			style = "form"
+		default:
+			style = "simple"
+		}
+	}
+	switch style {
+	case "form":
+		// For form style, explode defaults to true
+		if e, ok := explode.(bool); ok && !e {
+			return "csv"
+		}
+		return "multi"
+	case "spaceDelimited":
+		return "ssv"
+	case "pipeDelimited":
+		return "pipes"
+	}
+	return "csv"
+}
+
+// -----------------------------------------------------------------------------
+// convertSchema rewrites an OpenAPI 3.x schema object, recursively, into its
+// Swagger 2.0 form.
+func convertSchema(v interface{}) interface{} {
+
+	s := asMap(v)
+	if s == nil {
+		return v
+	}
+
+	schema := make(map[string]interface{}, len(s))
+	for k, val := range s {
+		schema[k] = val
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		schema["$ref"] = convertRef(ref)
+	}
+
+	// OpenAPI 3.1 declares nullable types as a type array including "null"
+	if types := asSlice(schema["type"]); types != nil {
+		var nonNull []interface{}
+		for _, t := range types {
+			if t != "null" {
+				nonNull = append(nonNull, t)
+			}
+		}
+		switch len(nonNull) {
+		case 0:
+			delete(schema, "type")
+		case 1:
+			schema["type"] = nonNull[0]
+		default:
+			schema["type"] = nonNull
+		}
+	}
+	delete(schema, "nullable")
+
+	// OpenAPI 3.1 exclusive bounds are numbers, rather than flags on minimum/maximum
+	for _, bound := range []string{"Minimum", "Maximum"} {
+		exclusive := "exclusive" + bound
+		if n, ok := schema[exclusive].(float64); ok {
+			schema[strings.ToLower(bound)] = n
+			schema[exclusive] = true
+		}
+	}
+
+	if constant, ok := schema["const"]; ok {
+		schema["enum"] = []interface{}{constant}
+		delete(schema, "const")
+	}
+	if examples := asSlice(schema["examples"]); len(examples) > 0 {
+		if _, ok := schema["example"]; !ok {
+			schema["example"] = examples[0]
+		}
+		delete(schema, "examples")
+	}
+
+	if items, ok := schema["items"]; ok {
+		schema["items"] = convertSchema(items)
+	}
+	if ap := asMap(schema["additionalProperties"]); ap != nil {
+		schema["additionalProperties"] = convertSchema(ap)
+	}
+	if not, ok := schema["not"]; ok {
+		schema["not"] = convertSchema(not)
+	}
+	if props := asMap(schema["properties"]); props != nil {
+		converted := make(map[string]interface{}, len(props))
+		for name, prop := range props {
+			converted[name] = convertSchema(prop)
+		}
+		schema["properties"] = converted
+	}
+	for _, composition := range []string{"allOf", "oneOf", "anyOf"} {
+		if list := asSlice(schema[composition]); list != nil {
+			converted := make([]interface{}, len(list))
+			for i, item := range list {
+				converted[i] = convertSchema(item)
+			}
+			schema[composition] = converted
+		}
+	}
+	if d := asMap(schema["discriminator"]); d != nil {
+		// Swagger 2.0 discriminators are simply the property name. Keep the mapping of
+		// values to schemas as an extension.
+		schema["discriminator"] = d["propertyName"]
+		if mapping := asMap(d["mapping"]); mapping != nil {
+			converted := make(map[string]interface{}, len(mapping))
+			for value, ref := range mapping {
+				if r, ok := ref.(string); ok && strings.HasPrefix(r, "#/") {
+					ref = convertRef(r)
+				}
+				converted[value] = ref
+			}
+			schema["x-discriminatorMapping"] = converted
+		}
+	}
+
+	return schema
+}
+
+// -----------------------------------------------------------------------------
+// withMediaExample carries a media type example onto an inline schema which has no
+// example of its own.
+func withMediaExample(schema interface{}, media map[string]interface{}) interface{} {
+	s := asMap(schema)
+	if s == nil {
+		return schema
+	}
+	if _, ok := s["$ref"]; ok {
+		return schema
+	}
+	if _, ok := s["example"]; ok {
+		return schema
+	}
+	if example, ok := media["example"]; ok {
+		s["example"] = example
+	} else if examples := asMap(media["examples"]); examples != nil {
+		for _, name := range sortedKeys(examples) {
+			if value, ok := asMap(examples[name])["value"]; ok {
+				s["example"] = value
+				break
+			}
+		}
+	}
+	return s
+}
+
+// -----------------------------------------------------------------------------
+
+func convertRef(ref string) string {
+	if strings.HasPrefix(ref, "#/components/schemas/") {
+		return "#/definitions/" + strings.TrimPrefix(ref, "#/components/schemas/")
+	}
+	return ref
+}
+
+// -----------------------------------------------------------------------------
+// preferredMediaType picks the media type whose schema documents a request or
+// response: JSON if offered, otherwise the first in alphabetical order.
+func preferredMediaType(mediaTypes []string) string {
+	for _, mt := range mediaTypes {
+		if mt == "application/json" {
+			return mt
+		}
+	}
+	for _, mt := range mediaTypes {
+		if strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "/json") {
+			return mt
+		}
+	}
+	if len(mediaTypes) > 0 {
+		return mediaTypes[0]
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+
+func isFormMediaType(mt string) bool {
+	return mt == "application/x-www-form-urlencoded" || mt == "multipart/form-data"
+}
+
+// -----------------------------------------------------------------------------
+// Helpers for walking generic JSON documents
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+func asString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func copyMembers(dst, src map[string]interface{}, names ...string) {
+	for _, name := range names {
+		if v, ok := src[name]; ok {
+			dst[name] = v
+		}
+	}
+}
+
+func copyExtensions(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if strings.HasPrefix(k, "x-") {
+			dst[k] = v
+		}
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch t := m.(type) {
+	case map[string]interface{}:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]bool:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func unescapePointer(s string) string {
+	return strings.Replace(strings.Replace(s, "~1", "/", -1), "~0", "~", -1)
+}
+
+// -----------------------------------------------------------------------------
+// end
@@ -0,0 +1,96 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Swagger 2.0 parameters and headers declare their type directly, so a schema they
+// reference must be resolved when converting, rather than left as a reference.
+func TestConvertReferencedParameterSchema(t *testing.T) {
+	doc := `{
+	  "openapi": "3.0.0",
+	  "info": {"title": "Pets", "version": "1.0"},
+	  "paths": {
+	    "/pets": {
+	      "get": {
+	        "parameters": [
+	          {"name": "status", "in": "query", "schema": {"$ref": "#/components/schemas/Status"}},
+	          {"name": "limit", "in": "query", "schema": {"$ref": "#/components/schemas/Limit"}},
+	          {"name": "tags", "in": "query", "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Status"}}}
+	        ],
+	        "responses": {
+	          "200": {
+	            "description": "The pets",
+	            "headers": {"X-Rate-Limit": {"schema": {"$ref": "#/components/schemas/Limit"}}}
+	          }
+	        }
+	      }
+	    }
+	  },
+	  "components": {
+	    "schemas": {
+	      "Status": {"type": "string", "enum": ["available", "sold"]},
+	      "Limit": {"$ref": "#/components/schemas/Count"},
+	      "Count": {"type": "integer", "format": "int32", "maximum": 100}
+	    }
+	  }
+	}`
+
+	raw, err := convertOpenAPI3(json.RawMessage(doc))
+	if err != nil {
+		t.Fatalf("conversion failed: %s", err)
+	}
+	var swagger map[string]interface{}
+	if err := json.Unmarshal(raw, &swagger); err != nil {
+		t.Fatalf("converted document is not JSON: %s", err)
+	}
+
+	get := asMap(asMap(asMap(swagger["paths"])["/pets"])["get"])
+	params := make(map[string]map[string]interface{})
+	for _, p := range asSlice(get["parameters"]) {
+		params[asString(asMap(p)["name"])] = asMap(p)
+	}
+	header := asMap(asMap(asMap(asMap(get["responses"])["200"])["headers"])["X-Rate-Limit"])
+
+	enum := []interface{}{"available", "sold"}
+	tests := []struct {
+		name   string
+		got    map[string]interface{}
+		member string
+		want   interface{}
+	}{
+		{"status", params["status"], "type", "string"},
+		{"status", params["status"], "enum", enum},
+		{"limit", params["limit"], "type", "integer"},
+		{"limit", params["limit"], "format", "int32"},
+		{"limit", params["limit"], "maximum", 100.0},
+		{"tags items", asMap(params["tags"]["items"]), "type", "string"},
+		{"tags items", asMap(params["tags"]["items"]), "enum", enum},
+		{"X-Rate-Limit", header, "type", "integer"},
+		{"X-Rate-Limit", header, "format", "int32"},
+	}
+	for _, test := range tests {
+		if got := test.got[test.member]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s %s is %v, want %v", test.name, test.member, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/go-openapi/spec"
+)
+
+// Polymorphic models are resources with alternative forms, or variants. They are
+// declared by:
+//   - oneOf or anyOf, listing the alternatives.
+//   - A discriminator on a model, with the variants being the models that inherit
+//     from it through allOf (the Swagger 2.0 approach).
+// A discriminator names the property whose value selects the variant. Its value is
+// the variant's definition name, unless mapped otherwise by the discriminator mapping
+// of an OpenAPI 3 specification, or an x-discriminator-value extension.
+//
+// References are replaced by the schema they reference when a specification is
+// expanded, losing the definition names. So before expansion, each definition is
+// annotated with its name, and each discriminated model with the names of the models
+// that inherit from it.
+
+// Vendor extensions added to definitions before expansion
+const (
+	definitionExtension = "x-dapperdox-definition"
+	subtypesExtension   = "x-dapperdox-subtypes"
+)
+
+// Kinds of polymorphism
+const (
+	VariantOneOf         = "oneOf"
+	VariantAnyOf         = "anyOf"
+	VariantDiscriminator = "discriminator"
+)
+
+// Variant is one of the alternative forms of a polymorphic resource
+type Variant struct {
+	Name               string
+	DiscriminatorValue string // Value of the discriminator property selecting this variant, if any
+	Resource           *Resource
+}
+
+// -----------------------------------------------------------------------------
+// Annotates the definitions of an unexpanded specification, so that the variants of
+// polymorphic models can be found and named once it has been expanded.
+func annotateDefinitions(s *spec.Swagger) {
+
+	subtypes := make(map[string][]string)
+
+	for name, definition := range s.Definitions {
+		for _, parent := range definition.AllOf {
+			if base := definitionName(parent.Ref.String()); base != "" {
+				subtypes[base] = append(subtypes[base], name)
+			}
+		}
+	}
+
+	for name, definition := range s.Definitions {
+		definition.AddExtension(definitionExtension, name)
+
+		if definition.Discriminator != "" && len(subtypes[name]) > 0 {
+			sort.Strings(subtypes[name])
+			definition.AddExtension(subtypesExtension, subtypes[name])
+		}
+		s.Definitions[name] = definition
+	}
+}
+
+// Returns the name of the definition referenced by ref, if it is a local reference.
+func definitionName(ref string) string {
+	if strings.HasPrefix(ref, "#/definitions/") {
+		return unescapePointer(strings.TrimPrefix(ref, "#/definitions/"))
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+// Compiles the variants of a polymorphic schema into the resource. A variant that is
+// a definition being compiled is referred to by ID, rather than compiled again.
+func (c *APISpecification) compileVariants(s *spec.Schema, r *Resource, method *Method, isRequestResource bool, compiling map[string]bool) {
+
+	var alternatives []spec.Schema
+
+	switch {
+	case len(s.OneOf) > 0:
+		r.VariantKind = VariantOneOf
+		alternatives = s.OneOf
+	case len(s.AnyOf) > 0:
+		r.VariantKind = VariantAnyOf
+		alternatives = s.AnyOf
+	case s.Discriminator != "":
+		for _, name := range stringList(s.Extensions[subtypesExtension]) {
+			if definition, ok := c.definitions[name]; ok {
+				alternatives = append(alternatives, definition)
+			}
+		}
+		if len(alternatives) > 0 {
+			r.VariantKind = VariantDiscriminator
+		}
+	}
+	if len(alternatives) == 0 {
+		return
+	}
+
+	r.Discriminator = s.Discriminator
+	mapping := discriminatorMapping(s)
+
+	for i := range alternatives {
+		alternative := alternatives[i] // A copy, as compiling a schema modifies it
+
+		definition, _ := alternative.Extensions[definitionExtension].(string)
+
+		name := alternative.Title
+		if name == "" {
+			name = definition
+		}
+		if name == "" {
+			name = fmt.Sprintf("Option %d", i+1)
+		}
+
+		variant := Variant{Name: name}
+
+		if r.Discriminator != "" {
+			variant.DiscriminatorValue = definition
+			if value, ok := mapping[definition]; ok {
+				variant.DiscriminatorValue = value
+			}
+			if value, ok := alternative.Extensions["x-discriminator-value"].(string); ok {
+				variant.DiscriminatorValue = value
+			}
+		}
+
+		logger.Tracef(nil, "Compile variant %s of %s\n", name, r.ID)
+
+		// Variants are compiled as stand alone resources, so need a title
+		if alternative.Title == "" {
+			alternative.Title = name
+		}
+		if compiling[definition] {
+			variant.Resource = &Resource{ID: TitleToKebab(alternative.Title), Title: alternative.Title, Type: []string{"object"}}
+		} else {
+			variant.Resource, _, _ = c.resourceFromSchema(&alternative, method, nil, isRequestResource, compiling)
+		}
+		if variant.Resource == nil {
+			continue
+		}
+		if variant.DiscriminatorValue != "" {
+			variant.Resource.Example = withProperty(variant.Resource.Example, r.Discriminator, variant.DiscriminatorValue)
+		}
+
+		r.Variants = append(r.Variants, variant)
+	}
+
+	// With no properties of its own, a resource is best exemplified by its first variant
+	if len(r.Properties) == 0 && r.Example == "" && len(r.Variants) > 0 {
+		r.Example = r.Variants[0].Resource.Example
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Returns the OpenAPI 3 discriminator mapping as definition name to discriminator value.
+func discriminatorMapping(s *spec.Schema) map[string]string {
+	mapping := make(map[string]string)
+
+	if m, ok := s.Extensions["x-discriminatorMapping"].(map[string]interface{}); ok {
+		for value, ref := range m {
+			if ref, ok := ref.(string); ok {
+				if name := definitionName(ref); name != "" {
+					mapping[name] = value
+				} else {
+					mapping[ref] = value // The mapping may name the schema, rather than reference it
+				}
+			}
+		}
+	}
+	return mapping
+}
+
+// -----------------------------------------------------------------------------
+// Sets a property in an example JSON object.
+func withProperty(example string, name string, value string) string {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(example), &obj); err != nil || obj == nil {
+		return example
+	}
+	obj[name] = value
+
+	b, err := JSONMarshalIndent(obj)
+	if err != nil {
+		return example
+	}
+	return string(b)
+}
+
+// -----------------------------------------------------------------------------
+
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		var s []string
+		for _, item := range list {
+			if str, ok := item.(string); ok {
+				s = append(s, str)
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
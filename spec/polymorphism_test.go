@@ -0,0 +1,71 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"testing"
+
+	"github.com/dapperdox/dapperdox/config"
+)
+
+// A Folder is a Node that contains Nodes, one of which may be a Folder, so compiling
+// the variants of a Node must not compile those of the Node's it contains forever.
+func TestRecursiveVariants(t *testing.T) {
+	cfg := config.New()
+	cfg.SpecDir = "testdata"
+	cfg.SpecFilename = []string{"/nodes.json"}
+	cfg.DefaultAssetsDir = "../assets"
+
+	suite, failures := NewLoader(cfg).LoadSuite(false)
+	if len(failures) > 0 {
+		t.Fatalf("specification failed to load: %v", failures)
+	}
+	specification, ok := suite["nodes"]
+	if !ok {
+		t.Fatalf("specification not loaded")
+	}
+	node := specification.ResourceList[specification.Version]["node"]
+	if node == nil {
+		t.Fatalf("resource node not compiled")
+	}
+
+	variants := make(map[string]*Resource)
+	for _, variant := range node.Variants {
+		variants[variant.Name] = variant.Resource
+	}
+	if len(variants) != 2 || variants["File"] == nil || variants["Folder"] == nil {
+		t.Fatalf("variants of node are %v, want File and Folder", node.Variants)
+	}
+
+	children := variants["Folder"].Properties["children"]
+	if children == nil {
+		t.Fatalf("Folder has no children property")
+	}
+	for _, variant := range children.Variants {
+		if variant.Name != "Folder" {
+			continue
+		}
+		if variant.Resource == nil || variant.Resource.ID != "folder" {
+			t.Errorf("Folder in children is %v, want a reference to folder", variant.Resource)
+		} else if len(variant.Resource.Properties) > 0 {
+			t.Errorf("Folder in children is compiled again, want a reference to folder")
+		}
+		return
+	}
+	t.Errorf("children has no Folder variant")
+}
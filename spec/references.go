@@ -0,0 +1,204 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// When a specification cannot be expanded, the error does not always say which
+// document holds the reference that could not be resolved. So the references are
+// checked, document by document, to find it.
+
+type referenceChecker struct {
+	loader    *Loader
+	documents map[string]interface{} // Documents loaded, keyed by location
+	checked   map[string]bool
+}
+
+// -----------------------------------------------------------------------------
+// Returns an error naming the document and reference, for the first reference from
+// the specification at location, or from the documents it references, that cannot
+// be resolved.
+func (l *Loader) checkReferences(location string, raw json.RawMessage) error {
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return fmt.Errorf("%s: %s", location, err)
+	}
+	rc := &referenceChecker{
+		loader:    l,
+		documents: map[string]interface{}{location: document},
+		checked:   make(map[string]bool),
+	}
+	return rc.check(location)
+}
+
+// -----------------------------------------------------------------------------
+
+func (rc *referenceChecker) check(location string) error {
+	if rc.checked[location] {
+		return nil
+	}
+	rc.checked[location] = true
+
+	document, err := rc.load(location)
+	if err != nil {
+		return err
+	}
+
+	var refs []string
+	collectReferences(document, &refs)
+
+	var targets []string
+	for _, ref := range refs {
+		target, pointer, err := ResolveReference(location, ref)
+		if err != nil {
+			return fmt.Errorf("%s: invalid reference %s: %s", location, ref, err)
+		}
+		doc, err := rc.load(target)
+		if err != nil {
+			return fmt.Errorf("%s: cannot resolve reference %s: %s", location, ref, err)
+		}
+		if _, err := lookupPointer(doc, pointer); err != nil {
+			return fmt.Errorf("%s: cannot resolve reference %s: %s", location, ref, err)
+		}
+		targets = append(targets, target)
+	}
+
+	for _, target := range targets {
+		if err := rc.check(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (rc *referenceChecker) load(location string) (interface{}, error) {
+	if document, ok := rc.documents[location]; ok {
+		return document, nil
+	}
+	raw, err := rc.loader.loadReferencedDocument(location)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("%s: %s", location, err)
+	}
+	rc.documents[location] = document
+	return document, nil
+}
+
+// -----------------------------------------------------------------------------
+// Appends the references made within v, in a stable order.
+func collectReferences(v interface{}, refs *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			*refs = append(*refs, ref)
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectReferences(v[key], refs)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectReferences(item, refs)
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// ResolveReference returns the location of the document a reference refers to,
+// relative to the document at location it is in, and the JSON pointer into it.
+func ResolveReference(location string, ref string) (string, string, error) {
+	target, pointer := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		target, pointer = ref[:i], ref[i+1:]
+	}
+	if target == "" {
+		return location, pointer, nil
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return "", "", err
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", err
+	}
+	return base.ResolveReference(u).String(), pointer, nil
+}
+
+// -----------------------------------------------------------------------------
+// PointerTokens returns the unescaped reference tokens of a JSON pointer (RFC 6901),
+// as given in the fragment of a reference. An empty pointer refers to the whole
+// document, so has no tokens.
+func PointerTokens(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	if p, err := url.PathUnescape(pointer); err == nil {
+		pointer = p
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = unescapePointer(token)
+	}
+	return tokens
+}
+
+// -----------------------------------------------------------------------------
+
+func lookupPointer(document interface{}, pointer string) (interface{}, error) {
+	v := document
+	for _, token := range PointerTokens(pointer) {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("#%s not found", pointer)
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("#%s not found", pointer)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("#%s not found", pointer)
+		}
+	}
+	return v, nil
+}
+
+// -----------------------------------------------------------------------------
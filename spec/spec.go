@@ -22,16 +22,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
-	"os"
+	"path"
 	"regexp"
 	"sort"
 	"strings"
 
-	"github.com/dapperdox/dapperdox/config"
 	"github.com/dapperdox/dapperdox/logger"
 	//"github.com/davecgh/go-spew/spew"
 	"github.com/go-openapi/loads"
 	"github.com/go-openapi/spec"
+	"github.com/go-openapi/swag"
 	"github.com/serenize/snaker"
 	"github.com/shurcooL/github_flavored_markdown"
 )
@@ -46,9 +46,14 @@ type APISpecification struct {
 	DefaultSecurity     map[string]Security
 	ResourceList        map[string]map[string]*Resource // Version->ResourceName->Resource
 	APIVersions         map[string]APISet               // Version->APISet
-}
+	Diagnostics         Diagnostics                     // Problems found while loading the specification
+	Version             string                          // Revision of the specification, from x-version or info.version
+	Revisions           []*APISpecification             // Earlier revisions of the specification, oldest first
 
-var APISuite map[string]*APISpecification
+	basePath    string
+	definitions spec.Definitions // Expanded model definitions, for finding the variants of polymorphic models
+	loader      *Loader
+}
 
 // GetByName returns an API by name
 func (c *APISpecification) GetByName(name string) *APIGroup {
@@ -142,6 +147,7 @@ type Method struct {
 	Security        map[string]Security
 	APIGroup        *APIGroup
 	SortKey         string
+	CodeSamples     []CodeSample
 }
 
 // Parameter represents an API method parameter
@@ -189,7 +195,12 @@ type Resource struct {
 	ExcludeFromOperations []string
 	Methods               map[string]*Method
 	Enum                  []string
+	Discriminator         string    // Property selecting the variant of a polymorphic resource
+	VariantKind           string    // How the variants are declared: oneOf, anyOf or discriminator
+	Variants              []Variant // The alternative forms of a polymorphic resource
 	origin                ResourceOrigin
+	schema                *spec.Schema // The schema compiled, used to generate examples
+	untyped               bool         // The schema has no type, so is documented as an object but allows any value
 }
 
 type Header struct {
@@ -250,64 +261,98 @@ func (api *APIGroup) getMethodSortKey(path, method, operation, navigation, summa
 // -----------------------------------------------------------------------------
 // -----------------------------------------------------------------------------
 
-func LoadSpecifications(specHost string, collapse bool) error {
-
-	if APISuite == nil {
-		APISuite = make(map[string]*APISpecification)
-	}
-
-	cfg, err := config.Get()
-	if err != nil {
-		logger.Errorf(nil, "error configuring app: %s", err)
-		return err
-	}
+// LoadSuite loads the configured specifications, returning them keyed by ID.
+// Specifications with errors are returned separately as failures. Each call returns a
+// new suite, so that specifications can be reloaded while the current suite
+// continues to be served.
+func (l *Loader) LoadSuite(collapse bool) (map[string]*APISpecification, map[string]*APISpecification) {
 
-	if strings.HasPrefix(specHost, "0.0.0.0") {
-		splithost := strings.Split(specHost, ":")
-		splithost[0] = "127.0.0.1"
-		specHost = strings.Join(splithost, ":")
-		logger.Tracef(nil, "Serving specifications from %s\n", specHost)
-	}
+	suite := make(map[string]*APISpecification)
+	failures := make(map[string]*APISpecification)
+	var failed []*APISpecification
 
-	for _, specLocation := range cfg.SpecFilename {
+	for _, specLocation := range l.cfg.SpecFilename {
 
 		var ok bool
 		var specification *APISpecification
 
-		if specification, ok = APISuite[""]; !ok || !collapse {
-			specification = &APISpecification{}
+		if specification, ok = suite[""]; !ok || !collapse {
+			specification = &APISpecification{loader: l.ForSpec(l.specID(specLocation), specLocation)}
 		}
 
-		err = specification.Load(specLocation, specHost)
-		if err != nil {
-			return err
+		diagnostics := specification.Load(specLocation)
+		diagnostics.log()
+
+		if diagnostics.HasErrors() {
+			logger.Errorf(nil, "Error: Specification %s has %d errors and will not be documented\n", specLocation, diagnostics.Errors())
+			failed = append(failed, specification)
+			continue
 		}
 
 		if collapse {
 			//specification.ID = "api"
 		}
 
-		APISuite[specification.ID] = specification
+		// Specifications sharing an ID are revisions of the same specification. The last
+		// configured is documented, and the earlier revisions kept for comparison.
+		if previous, ok := suite[specification.ID]; ok && previous != specification {
+			logger.Infof(nil, "Specification %s is a revision of %s", specLocation, previous.URL)
+			specification.Revisions = append(previous.Revisions, previous)
+			previous.Revisions = nil
+		}
+
+		suite[specification.ID] = specification
 	}
 
-	return nil
+	for _, specification := range suite {
+		specification.getVersions()
+	}
+
+	// A specification that failed to load must not take the place of one that loaded, or
+	// of another failure, so failures are given a unique ID.
+	for _, specification := range failed {
+		id := specification.ID
+		for n := 2; suite[id] != nil || failures[id] != nil; n++ {
+			id = fmt.Sprintf("%s-%d", specification.ID, n)
+		}
+		specification.ID = id
+		failures[id] = specification
+	}
+
+	return suite, failures
 }
 
 // -----------------------------------------------------------------------------
-// Load loads API specs from the supplied host (usually local!)
-func (c *APISpecification) Load(specLocation string, specHost string) error {
+// Load loads API specs from the spec-dir, or from a remote URL, returning any
+// problems found. The specification should not be documented if any of these are
+// errors. The specification is loaded by the Loader that created it.
+func (c *APISpecification) Load(specLocation string) Diagnostics {
 
 	if isLocalSpecUrl(specLocation) && !strings.HasPrefix(specLocation, "/") {
 		specLocation = "/" + specLocation
 	}
 
 	c.URL = specLocation
+	c.Diagnostics = nil
+
+	location := specLocation
+	var err error
+	if isLocalSpecUrl(specLocation) {
+		location, err = c.loader.localSpecPath(specLocation)
+	}
 
-	document, err := loadSpec(normalizeSpecLocation(specLocation, specHost))
+	var document *loads.Document
+	if err == nil {
+		document, err = c.loader.loadSpec(location)
+	}
 	if err != nil {
-		return err
+		c.ID = specIDFromLocation(specLocation)
+		c.APIInfo.Title = specLocation
+		c.addError("", "%s", err)
+		return c.Diagnostics
 	}
 	apispec := document.Spec()
+	c.definitions = apispec.Definitions
 
 	basePath := apispec.BasePath
 	basePathLen := len(basePath)
@@ -315,6 +360,9 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 	if basePathLen == 1 && basePath[0] == '/' {
 		basePathLen = 0
 	}
+	if basePathLen > 0 {
+		c.basePath = basePath
+	}
 
 	scheme := "http"
 	if apispec.Schemes != nil {
@@ -323,21 +371,32 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 
 	u, err := url.Parse(scheme + "://" + apispec.Host)
 	if err != nil {
-		return err
+		c.addError("/host", "Invalid host: %s", err)
+		u = &url.URL{Scheme: scheme}
+	}
+
+	var info spec.Info
+	if apispec.Info != nil {
+		info = *apispec.Info
 	}
+	c.APIInfo.Description = string(github_flavored_markdown.Markdown([]byte(info.Description)))
+	c.APIInfo.Title = info.Title
 
-	c.APIInfo.Description = string(github_flavored_markdown.Markdown([]byte(apispec.Info.Description)))
-	c.APIInfo.Title = apispec.Info.Title
+	c.Version = info.Version
+	if version, ok := apispec.Extensions["x-version"].(string); ok {
+		c.Version = version
+	}
 
 	if len(c.APIInfo.Title) == 0 {
-		logger.Errorf(nil, "Error: Specification %s does not have a info.title member.\n", c.URL)
-		os.Exit(1)
+		c.addError("/info/title", "Specification does not have a info.title member")
+		c.ID = specIDFromLocation(specLocation)
+		c.APIInfo.Title = specLocation
+	} else {
+		c.ID = TitleToKebab(c.APIInfo.Title)
 	}
 
 	logger.Tracef(nil, "Parse OpenAPI specification '%s'\n", c.APIInfo.Title)
 
-	c.ID = TitleToKebab(c.APIInfo.Title)
-
 	c.getSecurityDefinitions(apispec)
 	c.getDefaultSecurity(apispec)
 
@@ -348,10 +407,10 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 
 	var methodSortBy []string
 	if sortByList, ok := apispec.Extensions["x-sortMethodsBy"].([]interface{}); ok {
-		for _, sortBy := range sortByList {
-			keyname := sortBy.(string)
+		for i, sortBy := range sortByList {
+			keyname, _ := sortBy.(string)
 			if _, ok := sortTypes[keyname]; !ok {
-				logger.Errorf(nil, "Error: Invalid x-sortBy value %s\n", keyname)
+				c.addWarning(fmt.Sprintf("/x-sortMethodsBy/%d", i), "Invalid x-sortMethodsBy value %v", sortBy)
 			} else {
 				methodSortBy = append(methodSortBy, keyname)
 			}
@@ -364,7 +423,7 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 	// Use the top level TAGS to order the API resources/endpoints
 	// If Tags: [] is not defined, or empty, then no filtering or ordering takes place,
 	// and all API paths will be documented..
-	for _, tag := range getTags(apispec) {
+	for i, tag := range getTags(apispec) {
 		logger.Tracef(nil, "  In tag loop...\n")
 		// Tag matching may not be as expected if multiple paths have the same TAG (which is technically permitted)
 		var ok bool
@@ -420,12 +479,14 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 
 			var ver string
 			if ver, ok = pathItem.Extensions["x-version"].(string); !ok {
+				ver = c.Version
+			}
+			if ver == "" {
 				ver = "latest"
 			}
 			api.CurrentVersion = ver
 
-			c.getMethods(tag, api, &api.Methods, &pathItem, path, ver) // Current version
-			//c.getVersions(tag, api, pathItem.Versions, path)           // All versions
+			c.getMethods(tag, api, &api.Methods, &pathItem, path, ver) // Current version. Earlier versions come from revisions.
 
 			// If API was populated (will not be if tags do not match), add to set
 			if !groupingByTag && len(api.Methods) > 0 {
@@ -441,24 +502,34 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 
 			sort.Sort(SortMethods(api.Methods))
 			c.APIs = append(c.APIs, *api) // All APIs (versioned within)
+		} else if groupingByTag {
+			c.addWarning(fmt.Sprintf("/tags/%d", i), "Tag '%s' is not used by any operation, so will not be documented", tag.Name)
 		}
 	}
 
-	// Build a API map, grouping by version
+	c.checkMethodIDs()
+
+	return c.Diagnostics
+}
+
+// -----------------------------------------------------------------------------
+// Checks that no two methods of an API share an ID, as the ID forms the method's
+// page URL. IDs are derived from the operationId, summary or x-operationName, and
+// distinct names may be the same once converted by CamelToKebab.
+func (c *APISpecification) checkMethodIDs() {
+	seen := make(map[string]Method)
+
 	for _, api := range c.APIs {
-		for v, _ := range api.Versions {
-			if c.APIVersions == nil {
-				c.APIVersions = make(map[string]APISet)
+		for _, method := range api.Methods {
+			key := api.ID + "/" + method.ID
+			if other, ok := seen[key]; ok {
+				c.addWarning(c.operationPointer(method.Path, method.Method), "%s %s has the method ID '%s', which is already used by %s %s, so only one will be documented",
+					strings.ToUpper(method.Method), method.Path, method.ID, strings.ToUpper(other.Method), other.Path)
+				continue
 			}
-			// Create copy of API and set Methods array to be correct for the version we are building
-			napi := api
-			napi.Methods = napi.Versions[v]
-			napi.Versions = nil
-			c.APIVersions[v] = append(c.APIVersions[v], napi) // Group APIs by version
+			seen[key] = method
 		}
 	}
-
-	return nil
 }
 
 // -----------------------------------------------------------------------------
@@ -477,17 +548,54 @@ func getTags(specification *spec.Swagger) []spec.Tag {
 
 // -----------------------------------------------------------------------------
 
-func (c *APISpecification) getVersions(tag spec.Tag, api *APIGroup, versions map[string]spec.PathItem, path string) {
-	if versions == nil {
-		return
+// Documents the earlier revisions of the specification as earlier versions of its
+// APIs. An API is versioned by the revisions that have an API with the same ID, each
+// revision's methods being documented under the revision's version. Versions of
+// APIs that are no longer in the specification are not documented.
+func (c *APISpecification) getVersions() {
+	c.APIVersions = nil
+
+	for i := range c.APIs {
+		c.APIs[i].Versions = nil
 	}
-	api.Versions = make(map[string][]Method)
 
-	for v, pi := range versions {
-		logger.Tracef(nil, "Process version %s\n", v)
-		var method []Method
-		c.getMethods(tag, api, &method, &pi, path, v)
-		api.Versions[v] = method
+	for _, revision := range c.Revisions {
+		v := revision.Version
+		if v == "" {
+			logger.Warnf(nil, "Revision %s of specification %s has no version, so is not documented as a version", revision.URL, c.ID)
+			continue
+		}
+
+		for _, rapi := range revision.APIs {
+			for i := range c.APIs {
+				api := &c.APIs[i]
+				if api.ID != rapi.ID || v == api.CurrentVersion {
+					continue
+				}
+				if api.Versions == nil {
+					api.Versions = map[string][]Method{api.CurrentVersion: api.Methods}
+				}
+				logger.Tracef(nil, "Process version %s of API %s\n", v, api.ID)
+				api.Versions[v] = rapi.Methods // A later revision with the same version replaces an earlier one
+			}
+		}
+	}
+
+	// Build a API map, grouping earlier versions by version
+	for _, api := range c.APIs {
+		for v := range api.Versions {
+			if v == api.CurrentVersion {
+				continue
+			}
+			if c.APIVersions == nil {
+				c.APIVersions = make(map[string]APISet)
+			}
+			// Create copy of API and set Methods array to be correct for the version we are building
+			napi := api
+			napi.Methods = napi.Versions[v]
+			napi.Versions = nil
+			c.APIVersions[v] = append(c.APIVersions[v], napi) // Group APIs by version
+		}
 	}
 }
 
@@ -581,11 +689,10 @@ func (c *APISpecification) getDefaultSecurity(spec *spec.Swagger) {
 }
 
 // -----------------------------------------------------------------------------
-func (p *Parameter) setType(src spec.Parameter) {
+func (p *Parameter) setType(src spec.Parameter) error {
 	if src.Type == "array" {
 		if len(src.CollectionFormat) == 0 {
-			logger.Errorf(nil, "Error: Request parameter %s is an array without declaring the collectionFormat.\n", src.Name)
-			os.Exit(1)
+			return fmt.Errorf("Request parameter %s is an array without declaring the collectionFormat", src.Name)
 		}
 		p.Type = append(p.Type, src.Type)
 		p.CollectionFormat = src.CollectionFormat
@@ -605,6 +712,7 @@ func (p *Parameter) setType(src spec.Parameter) {
 		ptype = format
 	}
 	p.Type = append(p.Type, ptype)
+	return nil
 }
 
 func (p *Parameter) setEnums(src spec.Parameter) {
@@ -616,7 +724,7 @@ func (p *Parameter) setEnums(src spec.Parameter) {
 	}
 	var es = make([]string, 0)
 	for _, e := range ea {
-		es = append(es, fmt.Sprintf("%s", e))
+		es = append(es, fmt.Sprintf("%v", e))
 	}
 	p.Enum = es
 }
@@ -625,6 +733,8 @@ func (p *Parameter) setEnums(src spec.Parameter) {
 
 func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem, o *spec.Operation, path, methodname string, version string) *Method {
 
+	pointer := c.operationPointer(path, methodname)
+
 	var opname string
 	var gotOpname bool
 
@@ -689,25 +799,31 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 	if api.Name == "" {
 		name := o.Summary
 		if name == "" {
-			logger.Errorf(nil, "Error: Operation '%s' does not have an operationId or summary member.", id)
-			os.Exit(1)
+			c.addError(pointer, "Operation '%s' does not have an operationId or summary member", id)
+			name = id // Carry on, so that any further problems are also reported
 		}
 		api.Name = name
 		api.ID = TitleToKebab(name)
+	} else if o.ID == "" && o.Summary == "" {
+		c.addWarning(pointer, "Operation does not have an operationId or summary member, so is identified as '%s'", id)
 	}
 
 	if c.ResourceList == nil {
 		c.ResourceList = make(map[string]map[string]*Resource)
 	}
 
-	for _, param := range o.Parameters {
+	for i, param := range o.Parameters {
+		paramPointer := fmt.Sprintf("%s/parameters/%d", pointer, i)
+
 		p := Parameter{
 			Name:        param.Name,
 			In:          param.In,
 			Description: string(github_flavored_markdown.Markdown([]byte(param.Description))),
 			Required:    param.Required,
 		}
-		p.setType(param)
+		if err := p.setType(param); err != nil {
+			c.addError(paramPointer, "%s", err)
+		}
 		p.setEnums(param)
 
 		switch strings.ToLower(param.In) {
@@ -717,11 +833,15 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 			method.PathParams = append(method.PathParams, p)
 		case "body":
 			if param.Schema == nil {
-				logger.Errorf(nil, "Error: 'in body' parameter %s is missing a schema declaration.\n", param.Name)
-				os.Exit(1)
+				c.addError(paramPointer, "'in body' parameter %s is missing a schema declaration", param.Name)
+				continue
 			}
 			var body map[string]interface{}
-			p.Resource, body, p.IsArray = c.resourceFromSchema(param.Schema, method, nil, true)
+			p.Resource, body, p.IsArray = c.resourceFromSchema(param.Schema, method, nil, true, make(map[string]bool))
+			if p.Resource == nil {
+				c.addError(paramPointer+"/schema", "%s %s references a model definition that does not have a title member", strings.ToUpper(method.Method), method.Path)
+				continue
+			}
 			p.Resource.Schema = jsonResourceToString(body, p.IsArray)
 			p.Resource.origin = RequestBody
 			method.BodyParam = &p
@@ -735,12 +855,13 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 
 	// Compile resources from response declaration
 
+	var responses spec.Responses
 	if o.Responses == nil {
-		logger.Errorf(nil, "Error: Operation %s %s is missing a responses declaration.\n", methodname, path)
-		os.Exit(1)
+		c.addError(pointer, "Operation %s %s is missing a responses declaration", methodname, path)
+	} else {
+		responses = *o.Responses
 	}
-	// FIXME - Dies if there are no responses...
-	for status, response := range o.Responses.StatusCodeResponses {
+	for status, response := range responses.StatusCodeResponses {
 		logger.Tracef(nil, "Response for status %d", status)
 		//spew.Dump(response)
 
@@ -751,14 +872,14 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 				c.ResourceList[version] = make(map[string]*Resource)
 			}
 		}
-		rsp := c.buildResponse(&response, method, version)
-		(*rsp).StatusDescription = HTTPStatusDescription(status)
+		rsp := c.buildResponse(&response, method, version, fmt.Sprintf("%s/responses/%d", pointer, status))
+		(*rsp).StatusDescription = c.loader.httpStatusDescription(status)
 		method.Responses[status] = *rsp
 
 	}
 
-	if o.Responses.Default != nil {
-		rsp := c.buildResponse(o.Responses.Default, method, version)
+	if responses.Default != nil {
+		rsp := c.buildResponse(responses.Default, method, version, pointer+"/responses/default")
 		method.DefaultResponse = rsp
 	}
 
@@ -768,12 +889,14 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 		method.Security = c.DefaultSecurity
 	}
 
+	method.CodeSamples = c.codeSamples(method, o, pointer)
+
 	return method
 }
 
 // -----------------------------------------------------------------------------
 
-func (c *APISpecification) buildResponse(resp *spec.Response, method *Method, version string) *Response {
+func (c *APISpecification) buildResponse(resp *spec.Response, method *Method, version string, pointer string) *Response {
 	var response *Response
 
 	if resp != nil {
@@ -783,12 +906,14 @@ func (c *APISpecification) buildResponse(resp *spec.Response, method *Method, ve
 		var example_json map[string]interface{}
 
 		if resp.Schema != nil {
-			r, example_json, is_array = c.resourceFromSchema(resp.Schema, method, nil, false)
+			r, example_json, is_array = c.resourceFromSchema(resp.Schema, method, nil, false, make(map[string]bool))
 
 			if r != nil {
 				r.Schema = jsonResourceToString(example_json, false)
 				r.origin = MethodResponse
 				vres = c.crossLinkMethodAndResource(r, method, version)
+			} else {
+				c.addError(pointer+"/schema", "%s %s references a model definition that does not have a title member", strings.ToUpper(method.Method), method.Path)
 			}
 		}
 		response = &Response{
@@ -798,7 +923,7 @@ func (c *APISpecification) buildResponse(resp *spec.Response, method *Method, ve
 		}
 		method.Resources = append(method.Resources, response.Resource) // Add the resource to the method which uses it
 
-		response.compileHeaders(resp)
+		c.compileHeaders(response, resp, pointer)
 	}
 	return response
 }
@@ -889,30 +1014,27 @@ func getEnums(h spec.Header) []string {
 	}
 	var es = make([]string, 0)
 	for _, e := range ea {
-		es = append(es, fmt.Sprintf("%s", e))
+		es = append(es, fmt.Sprintf("%v", e))
 	}
 	return es
 }
 
-var collectionTable *map[string]string
+var collectionTable = map[string]string{
+	"csv":   "comma separated",
+	"ssv":   "space separated",
+	"tsv":   "tab separated",
+	"pipes": "pipe separated",
+	"multi": "multiple occurances",
+}
 
 func collectionFormatDescription(format string) string {
-	if collectionTable == nil {
-		collectionTable = &map[string]string{
-			"csv":   "comma separated",
-			"ssv":   "space separated",
-			"tsv":   "tab separated",
-			"pipes": "pipe separated",
-			"multi": "multiple occurances",
-		}
-	}
-	if desc, ok := (*collectionTable)[format]; ok {
+	if desc, ok := collectionTable[format]; ok {
 		return desc
 	}
 	return ""
 }
 
-func (r *Response) compileHeaders(sr *spec.Response) {
+func (c *APISpecification) compileHeaders(r *Response, sr *spec.Response, pointer string) {
 
 	if sr.Headers == nil {
 		return
@@ -927,8 +1049,7 @@ func (r *Response) compileHeaders(sr *spec.Response) {
 		htype := getType(params)
 		if params.Type == "array" {
 			if len(params.CollectionFormat) == 0 {
-				logger.Errorf(nil, "Error: Response header %s is an array without declaring the collectionFormat.\n", name)
-				os.Exit(1)
+				c.addError(pointer+"/headers/"+escapePointer(name), "Response header %s is an array without declaring the collectionFormat", name)
 			}
 			header.Type = append(header.Type, params.Type)
 			header.CollectionFormat = params.CollectionFormat
@@ -940,6 +1061,9 @@ func (r *Response) compileHeaders(sr *spec.Response) {
 		}
 		header.Type = append(header.Type, htype)
 		header.Enum = getEnums(params)
+		if params.Default != nil {
+			header.Default = fmt.Sprintf("%v", params.Default)
+		}
 
 		r.Headers = append(r.Headers, *header)
 	}
@@ -1049,8 +1173,10 @@ func checkPropertyType(s *spec.Schema) string {
 }
 
 // -----------------------------------------------------------------------------
-
-func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fqNS []string, isRequestResource bool) (*Resource, map[string]interface{}, bool) {
+// Compiles a schema into a resource. The definitions being compiled, those enclosing
+// the schema, are given by compiling, and are referred to rather than compiled again
+// when they are variants of the schema, as a model may contain its own variants.
+func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fqNS []string, isRequestResource bool, compiling map[string]bool) (*Resource, map[string]interface{}, bool) {
 	if s == nil {
 		return nil, nil, false
 	}
@@ -1080,6 +1206,7 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 	//  two cases is to keep the top level "type" in the second case, and apply it to items.schema.Type,
 	//  reseting our schema variable to items.schema.
 
+	untyped := s.Type == nil
 	if s.Type == nil {
 		s.Type = append(s.Type, "object")
 	}
@@ -1096,6 +1223,7 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 			s = &s.Items.Schemas[0]
 			logger.Tracef(nil, "got s.Items.Schemas[0] for %s\n", s.Title)
 		}
+		untyped = s.Type == nil // The resource of an array describes its items
 		if s.Type == nil {
 			logger.Tracef(nil, "Got array of objects or object. Name %s\n", s.Title)
 			s.Type = stringorarray // Put back original type
@@ -1120,8 +1248,8 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 	id := TitleToKebab(s.Title)
 
 	if len(fqNS) == 0 && id == "" {
-		logger.Errorf(nil, "Error: %s %s references a model definition that does not have a title member.", strings.ToUpper(method.Method), method.Path)
-		os.Exit(1)
+		// A model definition without a title. The caller reports this, as it knows where the schema was declared.
+		return nil, nil, false
 	}
 
 	// Ignore ID (from title element) for all but child-objects...
@@ -1181,6 +1309,8 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 		Type:        s.Type,
 		Properties:  make(map[string]*Resource),
 		FQNS:        resourceFQNS,
+		schema:      s,
+		untyped:     untyped,
 	}
 
 	if s.Example != nil {
@@ -1193,7 +1323,7 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 
 	if len(s.Enum) > 0 {
 		for _, e := range s.Enum {
-			r.Enum = append(r.Enum, fmt.Sprintf("%s", e))
+			r.Enum = append(r.Enum, fmt.Sprintf("%v", e))
 		}
 	}
 
@@ -1211,11 +1341,28 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 	required := make(map[string]bool)
 	json_representation := make(map[string]interface{})
 
+	if definition, _ := s.Extensions[definitionExtension].(string); definition != "" && !compiling[definition] {
+		compiling[definition] = true
+		defer delete(compiling, definition)
+	}
+
 	logger.Tracef(nil, "Call compileproperties...\n")
-	c.compileproperties(s, r, method, id, required, json_representation, myFQNS, chopped, isRequestResource)
+	c.compileproperties(s, r, method, id, required, json_representation, myFQNS, chopped, isRequestResource, compiling)
 
 	for allof := range s.AllOf {
-		c.compileproperties(&s.AllOf[allof], r, method, id, required, json_representation, myFQNS, chopped, isRequestResource)
+		c.compileproperties(&s.AllOf[allof], r, method, id, required, json_representation, myFQNS, chopped, isRequestResource, compiling)
+	}
+
+	// An object whose only member is <key> is a map, so document it as map<string, T>
+	if value, ok := r.Properties["<key>"]; ok && len(r.Properties) == 1 && strings.ToLower(r.Type[0]) == "object" {
+		r.Type = spec.StringOrArray([]string{"map", mapValueType(value)})
+	}
+
+	c.compileVariants(s, r, method, isRequestResource, compiling)
+
+	// Top level resources without an example are given one generated from their properties
+	if len(fqNS) == 0 && r.Example == "" {
+		r.Example = r.generateExample()
 	}
 
 	logger.Tracef(nil, "resourceFromSchema done\n")
@@ -1228,7 +1375,7 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 // It uses the 'required' map to set when properties are required and builds a JSON
 // representation of the resource.
 //
-func (c *APISpecification) compileproperties(s *spec.Schema, r *Resource, method *Method, id string, required map[string]bool, json_rep map[string]interface{}, myFQNS []string, chopped bool, isRequestResource bool) {
+func (c *APISpecification) compileproperties(s *spec.Schema, r *Resource, method *Method, id string, required map[string]bool, json_rep map[string]interface{}, myFQNS []string, chopped bool, isRequestResource bool, compiling map[string]bool) {
 
 	// First, grab the required members
 	for _, n := range s.Required {
@@ -1236,23 +1383,26 @@ func (c *APISpecification) compileproperties(s *spec.Schema, r *Resource, method
 	}
 
 	for name, property := range s.Properties {
-		c.processProperty(&property, name, r, method, id, required, json_rep, myFQNS, chopped, isRequestResource)
+		c.processProperty(&property, name, r, method, id, required, json_rep, myFQNS, chopped, isRequestResource, compiling)
 	}
 
 	// Special case to deal with AdditionalProperties (which really just boils down to declaring a
-	// map of 'type' (string, int, object etc).
+	// map of 'type' (string, int, object etc). The value schema is compiled as a property named
+	// <key>, standing for any member of the map. additionalProperties: true allows values of any
+	// type, so is documented as a map of object.
 	if s.AdditionalProperties != nil && s.AdditionalProperties.Allows {
 		name := "<key>"
 		ap := s.AdditionalProperties.Schema
-		ap.Type = spec.StringOrArray([]string{"map", ap.Type[0]}) // massage type so that it is a map of 'type'
-
-		c.processProperty(ap, name, r, method, id, required, json_rep, myFQNS, chopped, isRequestResource)
+		if ap == nil {
+			ap = &spec.Schema{}
+		}
+		c.processProperty(ap, name, r, method, id, required, json_rep, myFQNS, chopped, isRequestResource, compiling)
 	}
 }
 
 // -----------------------------------------------------------------------------
 
-func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resource, method *Method, id string, required map[string]bool, json_rep map[string]interface{}, myFQNS []string, chopped bool, isRequestResource bool) {
+func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resource, method *Method, id string, required map[string]bool, json_rep map[string]interface{}, myFQNS []string, chopped bool, isRequestResource bool, compiling map[string]bool) {
 
 	newFQNS := prepareNamespace(myFQNS, id, name, chopped)
 
@@ -1260,7 +1410,7 @@ func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resou
 	var resource *Resource
 
 	logger.Tracef(nil, "A call resourceFromSchema for property %s\n", name)
-	resource, json_resource, _ = c.resourceFromSchema(s, method, newFQNS, isRequestResource)
+	resource, json_resource, _ = c.resourceFromSchema(s, method, newFQNS, isRequestResource, compiling)
 
 	skip := isRequestResource && resource.ReadOnly
 	if !skip && resource.ExcludeFromOperations != nil {
@@ -1333,11 +1483,7 @@ func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resou
 				json_rep[name] = array_obj
 			}
 		} else if strings.ToLower(r.Properties[name].Type[0]) == "map" { // not array, so a map?
-			if strings.ToLower(r.Properties[name].Type[1]) == "object" {
-				json_rep[name] = json_resource // A map of objects
-			} else {
-				json_rep[name] = r.Properties[name].Type[1] // map of primitive
-			}
+			json_rep[name] = json_resource // { "<key>": value }
 		} else {
 			// We're NOT an array, map or object, so a primitive
 			json_rep[name] = r.Properties[name].Type[0]
@@ -1349,6 +1495,21 @@ func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resou
 	return
 }
 
+// -----------------------------------------------------------------------------
+// Returns the name of the value type of a map. Objects are named by their title, so
+// that the value resource documented beneath the map can be identified.
+func mapValueType(value *Resource) string {
+	switch strings.ToLower(value.Type[0]) {
+	case "object":
+		if value.Title != "" {
+			return value.Title
+		}
+	case "map":
+		return "map<string, " + value.Type[1] + ">"
+	}
+	return strings.Join(value.Type, " of ")
+}
+
 // -----------------------------------------------------------------------------
 
 func prepareNamespace(myFQNS []string, id string, name string, chopped bool) []string {
@@ -1386,11 +1547,32 @@ func CamelToKebab(s string) string {
 
 // -----------------------------------------------------------------------------
 
-func loadSpec(url string) (*loads.Document, error) {
+func (l *Loader) loadSpec(url string) (*loads.Document, error) {
 
 	logger.Infof(nil, "Importing OpenAPI specifications from %s", url)
 
-	document, err := loads.Spec(url)
+	raw, err := l.loadDocument(url)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := specVersion(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", url, err)
+	}
+
+	switch {
+	case version == "2.0":
+	case strings.HasPrefix(version, "3."):
+		logger.Tracef(nil, "Converting OpenAPI %s specification to Swagger 2.0\n", version)
+		if raw, err = convertOpenAPI3(raw); err != nil {
+			return nil, fmt.Errorf("%s: %s", url, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported specification version '%s'. Expected swagger 2.0 or openapi 3.x", url, version)
+	}
+
+	document, err := loads.Analyzed(raw, "2.0")
 	if err != nil {
 		//logger.Errorf(nil, "Error: go-openapi/loads filed to load spec url [%s]: %s", url, err)
 		return nil, err
@@ -1400,16 +1582,113 @@ func loadSpec(url string) (*loads.Document, error) {
 	//	RelativeBase: "/Users/csmith1/src/go/src/github.com/dapperdox/dapperdox-demo/specifications",
 	//}
 
-	// TODO Allow relative references https://github.com/go-openapi/spec/issues/14
-	err = spec.ExpandSpec(document.Spec(), nil)
+	annotateDefinitions(document.Spec())
+
+	// References are relative to the document they are in, which may itself have been
+	// referenced from another. Referenced documents are fetched as the specification
+	// is, so may be local or remote, JSON or YAML.
+	options := &spec.ExpandOptions{
+		RelativeBase: url,
+		PathLoader:   l.loadReferencedDocument,
+	}
+	err = spec.ExpandSpec(document.Spec(), options)
 	if err != nil {
 		//logger.Errorf(nil, "Error: go-openapi/spec filed to expand spec: %s", err)
+		if refErr := l.checkReferences(url, raw); refErr != nil {
+			return nil, refErr
+		}
 		return nil, err
 	}
 
 	return document, nil
 }
 
+// -----------------------------------------------------------------------------
+// Returns the ID the specification at specLocation will be documented under, so
+// that the settings configured for that ID are applied as it is loaded. The ID is
+// read from the title of the specification document, which is only fetched if some
+// specification is configured with settings of its own. Load reports any error in
+// fetching it, so "" is returned for one.
+func (l *Loader) specID(specLocation string) string {
+	if len(l.cfg.SpecConfigs()) == 0 {
+		return ""
+	}
+
+	location := specLocation
+	if isLocalSpecUrl(specLocation) {
+		if !strings.HasPrefix(specLocation, "/") {
+			specLocation = "/" + specLocation
+		}
+		var err error
+		if location, err = l.localSpecPath(specLocation); err != nil {
+			return ""
+		}
+	}
+
+	raw, err := l.loadDocument(location)
+	if err != nil {
+		return ""
+	}
+	var document struct {
+		Info struct {
+			Title string `json:"title"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return ""
+	}
+	if document.Info.Title == "" {
+		return specIDFromLocation(specLocation)
+	}
+	return TitleToKebab(document.Info.Title)
+}
+
+// -----------------------------------------------------------------------------
+// Fetches a JSON or YAML specification document, returning it as JSON.
+func (l *Loader) loadDocument(location string) (json.RawMessage, error) {
+	var b []byte
+	var err error
+
+	file, local := localFile(location)
+	if local {
+		b, err = l.readLocalDocument(file)
+	} else if raw, ok := l.remote[location]; ok {
+		return raw, nil
+	} else {
+		b, err = swag.LoadFromFileOrHTTP(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw := json.RawMessage(b)
+	if !strings.HasSuffix(strings.ToLower(location), ".json") {
+		doc, err := swag.BytesToYAMLDoc(b) // YAML is a superset of JSON, so this handles both
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = swag.YAMLToJSON(doc); err != nil {
+			return nil, err
+		}
+	}
+	if !local {
+		l.remote[location] = raw
+	}
+	return raw, nil
+}
+
+// -----------------------------------------------------------------------------
+// Fetches a document referenced by the specification, naming it in any error.
+func (l *Loader) loadReferencedDocument(location string) (json.RawMessage, error) {
+	logger.Tracef(nil, "Load referenced document %s\n", location)
+
+	raw, err := l.loadDocument(location)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", location, err)
+	}
+	return raw, nil
+}
+
 // -----------------------------------------------------------------------------
 // Wrapper around MarshalIndent to prevent < > & from being escaped
 func JSONMarshalIndent(v interface{}) ([]byte, error) {
@@ -1421,6 +1700,17 @@ func JSONMarshalIndent(v interface{}) ([]byte, error) {
 	return b, err
 }
 
+// -----------------------------------------------------------------------------
+// Derives a specification ID from its location, for use when it has no title. The
+// whole path is used, as specifications in different directories often share a name.
+func specIDFromLocation(specLocation string) string {
+	if u, err := url.Parse(specLocation); err == nil && u.Host != "" {
+		specLocation = u.Host + u.Path
+	}
+	specLocation = strings.TrimSuffix(specLocation, path.Ext(specLocation))
+	return TitleToKebab(strings.Join(strings.FieldsFunc(specLocation, func(r rune) bool { return r == '/' }), " "))
+}
+
 // -----------------------------------------------------------------------------
 
 func isLocalSpecUrl(specUrl string) bool {
@@ -1431,12 +1721,3 @@ func isLocalSpecUrl(specUrl string) bool {
 	return !match
 }
 
-// -----------------------------------------------------------------------------
-
-func normalizeSpecLocation(specLocation string, specHost string) string {
-	if isLocalSpecUrl(specLocation) {
-		return "http://" + specHost + specLocation
-	} else {
-		return specLocation
-	}
-}
@@ -27,13 +27,12 @@ import (
 )
 
 var statusMapSplit = regexp.MustCompile(",")
-var StatusCodes map[int]string
 
-func LoadStatusCodes() {
+// Returns the descriptions of HTTP status codes, from the status_codes.csv file of the
+// assets, or of the theme.
+func loadStatusCodes(cfg *config.Config) map[int]string {
 	var statusfile string
 
-	cfg, _ := config.Get()
-
 	if len(cfg.AssetsDir) != 0 {
 		statusfile = cfg.AssetsDir + "/status_codes.csv"
 		logger.Tracef(nil, "Looking in assets dir for %s\n", statusfile)
@@ -65,18 +64,18 @@ func LoadStatusCodes() {
 
 	if len(statusfile) == 0 {
 		logger.Tracef(nil, "No status code map file found.")
-		return
+		return nil
 	}
 	logger.Tracef(nil, "Processing HTTP status code file: %s\n", statusfile)
 	file, err := os.Open(statusfile)
 
 	if err != nil {
 		logger.Errorf(nil, "Error: %s", err)
-		return
+		return nil
 	}
 	defer file.Close()
 
-	StatusCodes = make(map[int]string)
+	statusCodes := make(map[int]string)
 
 	scanner := bufio.NewScanner(file)
 
@@ -85,7 +84,7 @@ func LoadStatusCodes() {
 
 		indexes := statusMapSplit.FindStringIndex(line)
 		if indexes == nil {
-			return
+			return statusCodes
 		}
 		i, err := strconv.Atoi(line[0 : indexes[1]-1])
 		if err != nil {
@@ -95,16 +94,17 @@ func LoadStatusCodes() {
 		status := i
 		desc := line[indexes[1]:]
 
-		StatusCodes[status] = string(desc)
+		statusCodes[status] = string(desc)
 	}
 
 	if err := scanner.Err(); err != nil {
 		logger.Errorf(nil, "Error: %s", err)
 	}
+	return statusCodes
 }
 
-func HTTPStatusDescription(status int) string {
-	if desc, ok := StatusCodes[status]; ok {
+func (l *Loader) httpStatusDescription(status int) string {
+	if desc, ok := l.statusCodes[status]; ok {
 		return desc
 	}
 	return ""
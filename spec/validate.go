@@ -0,0 +1,540 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Requests are validated against the compiled methods and resources, rather than
+// the specification's schemas, so that what is checked is what is documented. As
+// formats replace type names in the compiled types, a type is either a JSON schema
+// type or a format, whose base type is given by formatTypes.
+
+// Violation describes a documented constraint that a request or response does not meet
+type Violation struct {
+	In      string `json:"in"`             // Where the violation is: status, path, query, header, formData or body
+	Name    string `json:"name,omitempty"` // Parameter name, or JSON pointer to the member of the body
+	Message string `json:"message"`
+}
+
+// Base types of formats
+var formatTypes = map[string]string{
+	"int32":  "integer",
+	"int64":  "integer",
+	"uint32": "integer",
+	"uint64": "integer",
+	"float":  "number",
+	"double": "number",
+}
+
+// Nesting beyond which bodies are not validated, in case of recursive models
+const maxValidateDepth = 32
+
+// -----------------------------------------------------------------------------
+
+func (v Violation) String() string {
+	if v.Name == "" {
+		return fmt.Sprintf("%s: %s", v.In, v.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", v.In, v.Name, v.Message)
+}
+
+// -----------------------------------------------------------------------------
+// FindMethod returns the documented method of suite matching an HTTP method
+// and request path, along with the values of the path parameters and the ID of the
+// specification documenting it. Where more than one path matches, the one with the
+// most literal segments is chosen, so that /pets/mine is preferred to /pets/{id}.
+// Returns nil if no method matches.
+func FindMethod(suite map[string]*APISpecification, verb string, path string) (*Method, map[string]string, string) {
+	var found *Method
+	var foundParams map[string]string
+	var foundSpec string
+	best := -1
+
+	for id, specification := range suite {
+		for _, api := range specification.APIs {
+			for i := range api.Methods {
+				method := &api.Methods[i]
+				if !strings.EqualFold(method.Method, verb) {
+					continue
+				}
+				params, literals, ok := matchPath(method.Path, path)
+				if ok && literals > best {
+					found, foundParams, foundSpec, best = method, params, id, literals
+				}
+			}
+		}
+	}
+	return found, foundParams, foundSpec
+}
+
+// Matches a request path against a path template, returning the values of its
+// parameters and the number of literal segments matched.
+func matchPath(template string, path string) (map[string]string, int, bool) {
+	ts := strings.Split(strings.Trim(template, "/"), "/")
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	if len(ts) != len(ps) {
+		return nil, 0, false
+	}
+
+	params := make(map[string]string)
+	literals := 0
+
+	for i, t := range ts {
+		open := strings.Index(t, "{")
+		close := strings.LastIndex(t, "}")
+		if open < 0 || close < open {
+			if t != ps[i] {
+				return nil, 0, false
+			}
+			literals++
+			continue
+		}
+		// A parameter, possibly with a literal prefix and suffix, such as {id}.json
+		prefix, suffix := t[:open], t[close+1:]
+		if !strings.HasPrefix(ps[i], prefix) || !strings.HasSuffix(ps[i], suffix) || len(ps[i]) <= len(prefix)+len(suffix) {
+			return nil, 0, false
+		}
+		value, err := url.PathUnescape(ps[i][len(prefix) : len(ps[i])-len(suffix)])
+		if err != nil {
+			return nil, 0, false
+		}
+		params[t[open+1:close]] = value
+	}
+	return params, literals, true
+}
+
+// -----------------------------------------------------------------------------
+// ValidateRequest checks a request against the parameters documented for the
+// method: that required parameters are present, and that parameter values and
+// the body have the documented types and enumerated values. pathParams are the
+// path parameter values, as returned by FindMethod. The request body is passed
+// separately, as it will usually need to be forwarded. Only a body for which
+// ValidatesBody is true is checked, so others need not be read.
+func (m *Method) ValidateRequest(req *http.Request, pathParams map[string]string, body []byte) []Violation {
+	var violations []Violation
+
+	for _, p := range m.PathParams {
+		violations = append(violations, p.validate(paramValues(p, pathParams[p.Name], pathParams[p.Name] != ""))...)
+	}
+
+	query := req.URL.Query()
+	for _, p := range m.QueryParams {
+		values, ok := query[p.Name]
+		if p.CollectionFormat != "multi" && len(values) > 0 {
+			violations = append(violations, p.validate(paramValues(p, values[0], ok))...)
+		} else {
+			violations = append(violations, p.validate(values, ok)...)
+		}
+	}
+
+	for _, p := range m.HeaderParams {
+		value := req.Header.Get(p.Name)
+		_, ok := req.Header[http.CanonicalHeaderKey(p.Name)]
+		violations = append(violations, p.validate(paramValues(p, value, ok))...)
+	}
+
+	contentType := req.Header.Get("Content-Type")
+
+	if len(m.FormParams) > 0 && mediaType(contentType) == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			violations = append(violations, Violation{In: "formData", Message: fmt.Sprintf("is not valid form data: %s", err)})
+		}
+		for _, p := range m.FormParams {
+			values, ok := form[p.Name]
+			if p.CollectionFormat != "multi" && len(values) > 0 {
+				violations = append(violations, p.validate(paramValues(p, values[0], ok))...)
+			} else {
+				violations = append(violations, p.validate(values, ok)...)
+			}
+		}
+	}
+
+	if p := m.BodyParam; p != nil && p.Resource != nil && ValidatesBody(contentType) {
+		violations = append(violations, p.validateBody(contentType, body)...)
+	}
+
+	return violations
+}
+
+// -----------------------------------------------------------------------------
+// ValidatesBody returns whether ValidateRequest checks a request body of the given
+// content type. Only JSON bodies, which is assumed when no type is given, and URL
+// encoded form data are.
+func ValidatesBody(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	t := mediaType(contentType)
+	return strings.Contains(t, "json") || t == "application/x-www-form-urlencoded"
+}
+
+// -----------------------------------------------------------------------------
+// ValidateResponse checks a response against those documented for the method: that
+// its status code is documented, that the documented headers are present with the
+// documented types and enumerated values, and that a JSON body matches the
+// documented resource.
+func (m *Method) ValidateResponse(status int, header http.Header, body []byte) []Violation {
+
+	response, ok := m.Responses[status]
+	if !ok {
+		if m.DefaultResponse == nil {
+			return []Violation{{In: "status", Message: fmt.Sprintf("%d is not a documented response", status)}}
+		}
+		response = *m.DefaultResponse
+	}
+
+	var violations []Violation
+
+	for _, h := range response.Headers {
+		value := header.Get(h.Name)
+		if _, ok := header[http.CanonicalHeaderKey(h.Name)]; !ok {
+			violations = append(violations, Violation{In: "header", Name: h.Name, Message: "is documented, but missing"})
+			continue
+		}
+		p := Parameter{Name: h.Name, In: "header", Type: h.Type, Enum: h.Enum, CollectionFormat: h.CollectionFormat}
+		violations = append(violations, p.validate(paramValues(p, value, true))...)
+	}
+
+	if response.Resource != nil && len(bytes.TrimSpace(body)) > 0 {
+		violations = append(violations, validateJSON(response.Resource, response.IsArray, header.Get("Content-Type"), body)...)
+	}
+	return violations
+}
+
+// -----------------------------------------------------------------------------
+// Splits a parameter value into its members, if the parameter is an array.
+func paramValues(p Parameter, value string, present bool) ([]string, bool) {
+	if !present {
+		return nil, false
+	}
+	if len(p.Type) < 2 {
+		return []string{value}, true
+	}
+	separator := ","
+	switch p.CollectionFormat {
+	case "ssv":
+		separator = " "
+	case "tsv":
+		separator = "\t"
+	case "pipes":
+		separator = "|"
+	}
+	return strings.Split(value, separator), true
+}
+
+// -----------------------------------------------------------------------------
+
+func (p Parameter) validate(values []string, present bool) []Violation {
+	in := p.In
+	if !present {
+		if p.Required {
+			return []Violation{{In: in, Name: p.Name, Message: "is required"}}
+		}
+		return nil
+	}
+
+	var violations []Violation
+	t := ""
+	if len(p.Type) > 0 {
+		t = p.Type[len(p.Type)-1]
+	}
+	for _, value := range values {
+		if message := checkString(value, t, p.Enum); message != "" {
+			violations = append(violations, Violation{In: in, Name: p.Name, Message: message})
+		}
+	}
+	return violations
+}
+
+// Checks a parameter value, returning a description of the failed constraint, or
+// an empty string if it has none.
+func checkString(value string, t string, enum []string) string {
+	var err error
+
+	switch baseType(t) {
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "number":
+		_, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(value)
+	case "date":
+		_, err = time.Parse("2006-01-02", value)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+	}
+	if err != nil {
+		return fmt.Sprintf("'%s' is not a valid %s", value, t)
+	}
+
+	if len(enum) > 0 && !contains(enum, value) {
+		return fmt.Sprintf("'%s' is not one of the possible values: %s", value, strings.Join(enum, ", "))
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+
+func (p *Parameter) validateBody(contentType string, body []byte) []Violation {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if p.Required {
+			return []Violation{{In: "body", Message: "is required"}}
+		}
+		return nil
+	}
+
+	return validateJSON(p.Resource, p.IsArray, contentType, body)
+}
+
+// -----------------------------------------------------------------------------
+// Checks a body against a resource. Only JSON bodies are checked. A resource is
+// shared by the methods using it, whether singly or in an array, so the resource
+// type cannot say which the body should be. isArray does.
+func validateJSON(r *Resource, isArray bool, contentType string, body []byte) []Violation {
+	if t := mediaType(contentType); t != "" && !strings.Contains(t, "json") {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []Violation{{In: "body", Message: fmt.Sprintf("is not valid JSON: %s", err)}}
+	}
+	var violations []Violation
+
+	if !isArray {
+		violations = r.validateItem("body", value, "", 0)
+	} else if items, ok := value.([]interface{}); !ok {
+		violations = []Violation{{In: "body", Name: "/", Message: "must be an array"}}
+	} else {
+		for i, item := range items {
+			violations = append(violations, r.validateItem("body", item, fmt.Sprintf("/%d", i), 0)...)
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Name < violations[j].Name })
+
+	return violations
+}
+
+// -----------------------------------------------------------------------------
+// Checks a value decoded from JSON against a resource. pointer is the JSON pointer
+// to the value, used to name it in violations.
+func (r *Resource) validateValue(in string, value interface{}, pointer string, depth int) []Violation {
+
+	// Nullable types are not retained when compiling, so null is accepted
+	if value == nil || depth > maxValidateDepth || len(r.Type) == 0 {
+		return nil
+	}
+	violation := func(format string, args ...interface{}) []Violation {
+		return []Violation{{In: in, Name: pointerName(pointer), Message: fmt.Sprintf(format, args...)}}
+	}
+
+	switch t := strings.ToLower(r.Type[0]); t {
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return violation("must be an array")
+		}
+		var violations []Violation
+		for i, item := range items {
+			violations = append(violations, r.validateItem(in, item, fmt.Sprintf("%s/%d", pointer, i), depth)...)
+		}
+		return violations
+	case "object", "map":
+		return r.validateObject(in, value, pointer, depth)
+	default:
+		if message := checkValue(value, t, r.Enum); message != "" {
+			return violation("%s", message)
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Checks a member of an array against the resource of the array. The resource of an
+// array describes its items, as its properties if they are objects, else as the
+// second element of its type.
+func (r *Resource) validateItem(in string, item interface{}, pointer string, depth int) []Violation {
+	if len(r.Type) == 0 || strings.ToLower(r.Type[0]) != "array" {
+		return r.validateValue(in, item, pointer, depth)
+	}
+	if len(r.Type) > 1 {
+		// An array of primitives. The item enumeration is that of the resource.
+		if message := checkValue(item, r.Type[1], r.Enum); message != "" {
+			return []Violation{{In: in, Name: pointerName(pointer), Message: message}}
+		}
+		return nil
+	}
+	return r.validateObject(in, item, pointer, depth)
+}
+
+// -----------------------------------------------------------------------------
+
+func (r *Resource) validateObject(in string, value interface{}, pointer string, depth int) []Violation {
+	freeForm := len(r.Properties) == 0 && len(r.Variants) == 0
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		if freeForm && r.untyped {
+			return nil // Any value, such as the values of additionalProperties: true
+		}
+		return []Violation{{In: in, Name: pointerName(pointer), Message: "must be an object"}}
+	}
+	if freeForm {
+		return nil
+	}
+
+	if len(r.Variants) > 0 {
+		return r.validateVariants(in, obj, pointer, depth)
+	}
+
+	var violations []Violation
+
+	for name, property := range r.Properties {
+		if _, ok := obj[name]; !ok && property.Required && name != "<key>" {
+			violations = append(violations, Violation{In: in, Name: pointerName(pointer + "/" + escapePointer(name)), Message: "is required"})
+		}
+	}
+	for name, member := range obj {
+		property, ok := r.Properties[name]
+		if !ok {
+			// Members not documented are either members of a map, or not checked
+			if property, ok = r.Properties["<key>"]; !ok {
+				continue
+			}
+		}
+		violations = append(violations, property.validateValue(in, member, pointer+"/"+escapePointer(name), depth+1)...)
+	}
+	return violations
+}
+
+// -----------------------------------------------------------------------------
+// A polymorphic object must match one of its variants. The variant is chosen by
+// the discriminator, if there is one, else any variant matching will do.
+func (r *Resource) validateVariants(in string, obj map[string]interface{}, pointer string, depth int) []Violation {
+
+	if r.Discriminator != "" {
+		value, _ := obj[r.Discriminator].(string)
+		for _, variant := range r.Variants {
+			if variant.DiscriminatorValue == value {
+				return variant.Resource.validateObject(in, obj, pointer, depth+1)
+			}
+		}
+		var values []string
+		for _, variant := range r.Variants {
+			values = append(values, variant.DiscriminatorValue)
+		}
+		return []Violation{{
+			In:      in,
+			Name:    pointerName(pointer + "/" + escapePointer(r.Discriminator)),
+			Message: fmt.Sprintf("'%s' is not one of the possible values: %s", value, strings.Join(values, ", ")),
+		}}
+	}
+
+	var names []string
+	for _, variant := range r.Variants {
+		if len(variant.Resource.validateValue(in, obj, pointer, depth+1)) == 0 {
+			return nil
+		}
+		names = append(names, variant.Name)
+	}
+	return []Violation{{In: in, Name: pointerName(pointer), Message: fmt.Sprintf("does not match any of %s", strings.Join(names, ", "))}}
+}
+
+// -----------------------------------------------------------------------------
+// Checks a primitive value decoded from JSON, returning a description of the
+// failed constraint, or an empty string if it has none.
+func checkValue(value interface{}, t string, enum []string) string {
+	if value == nil {
+		return ""
+	}
+
+	switch baseType(t) {
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			return "must be an " + typeName("integer", t)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return "must be a " + typeName("number", t)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case "object", "file":
+		return ""
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return "must be a " + typeName("string", t)
+		}
+		return checkString(s, t, enum)
+	}
+
+	if len(enum) > 0 && !contains(enum, fmt.Sprintf("%v", value)) {
+		return fmt.Sprintf("%v is not one of the possible values: %s", value, strings.Join(enum, ", "))
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+
+func baseType(t string) string {
+	t = strings.ToLower(t)
+	if base, ok := formatTypes[t]; ok {
+		return base
+	}
+	return t
+}
+
+// Names a type, with its format if it has one, such as "integer (int64)"
+func typeName(base string, t string) string {
+	if strings.EqualFold(base, t) || t == "" {
+		return base
+	}
+	return base + " (" + t + ")"
+}
+
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(t)
+}
+
+func pointerName(pointer string) string {
+	if pointer == "" {
+		return "/"
+	}
+	return pointer
+}
+
+// -----------------------------------------------------------------------------
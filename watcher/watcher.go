@@ -0,0 +1,109 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package watcher
+
+// This package watches directory trees for changes by periodically scanning them.
+// Polling is used, rather than filesystem notifications, so that behaviour is the
+// same on every platform and for network mounted directories.
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+type snapshot map[string]fileState
+
+// ---------------------------------------------------------------------------
+// Watch scans dirs every interval, calling onChange when files have been added,
+// removed or modified. onChange is only called once the directories have stopped
+// changing for a whole interval, so that a burst of edits causes a single reload.
+// Watch never returns, so is normally run as a goroutine.
+func Watch(dirs []string, interval time.Duration, onChange func()) {
+
+	for _, dir := range dirs {
+		logger.Infof(nil, "Watching %s for changes", dir)
+	}
+
+	last := scan(dirs)
+	pending := false
+
+	for range time.Tick(interval) {
+		current := scan(dirs)
+
+		if !current.equal(last) {
+			logger.Debugf(nil, "Change detected in watched directories")
+			last = current
+			pending = true
+			continue
+		}
+		if pending {
+			pending = false
+			onChange()
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func scan(dirs []string) snapshot {
+	s := make(snapshot)
+
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info == nil {
+				return nil
+			}
+			// Skip hidden files and directories, such as editor swap files
+			if name := info.Name(); path != dir && len(name) > 0 && name[0] == '.' {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				s[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			}
+			return nil
+		})
+	}
+	return s
+}
+
+// ---------------------------------------------------------------------------
+
+func (s snapshot) equal(o snapshot) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for path, state := range s {
+		if other, ok := o[path]; !ok || other.size != state.size || !other.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------------------------------------------------------